@@ -12,9 +12,9 @@ import (
 	"github.com/go-gl/glfw/v3.1/glfw"
 	"github.com/go-gl/mathgl/mgl32"
 
-	"github.com/egonelbre/opengl-tutorial.org/dds"
 	"github.com/egonelbre/opengl-tutorial.org/obj"
 	"github.com/egonelbre/opengl-tutorial.org/shaders"
+	"github.com/egonelbre/opengl-tutorial.org/textures"
 )
 
 const WindowWidth = 800
@@ -179,10 +179,12 @@ func main() {
 
 	checkerror()
 
-	texture, err := dds.LoadFile("cube.dds")
+	cache := textures.NewCache(textures.DefaultOptions)
+	texture, err := cache.Load("cube.dds")
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer texture.Release()
 
 	checkerror()
 
@@ -218,8 +220,7 @@ func main() {
 
 		gl.BindVertexArray(vao)
 
-		gl.ActiveTexture(gl.TEXTURE0)
-		gl.BindTexture(gl.TEXTURE_2D, texture)
+		texture.Bind(0)
 
 		gl.DrawArrays(gl.TRIANGLES, 0, 12*3)
 
@@ -0,0 +1,522 @@
+package dds
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+	"os"
+
+	"github.com/egonelbre/opengl-tutorial.org/internal/texutil"
+)
+
+// Quality selects the trade-off between compression speed and quality.
+type Quality int
+
+const (
+	// QualityFast uses the bounding box of the block colors as endpoints
+	QualityFast Quality = iota
+	// QualityNormal places the endpoints on the principal axis of the colors
+	QualityNormal
+	// QualityBest refines the endpoints with least squares fitting
+	QualityBest
+)
+
+// EncodeOptions control the block compression.
+type EncodeOptions struct {
+	// Format is BC1_UNORM, BC3_UNORM or their sRGB variants
+	Format  DXGIFormat
+	Quality Quality
+}
+
+// DefaultEncodeOptions compress to BC1.
+var DefaultEncodeOptions = EncodeOptions{
+	Format:  BC1_UNORM,
+	Quality: QualityNormal,
+}
+
+// EncodeFile compresses the mipmap levels and writes them to
+// filename, see EncodeLevels.
+func EncodeFile(filename string, levels []image.Image, opts EncodeOptions) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := EncodeLevels(file, levels, opts); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Encode compresses m without mipmaps and writes it as a DDS file.
+func Encode(w io.Writer, m image.Image, opts EncodeOptions) error {
+	return EncodeLevels(w, []image.Image{m}, opts)
+}
+
+// EncodeLevels compresses a mipmap chain and writes it as a DDS file.
+// The levels are usually generated with textures.GenerateMipmaps,
+// which filters in linear light for the sRGB formats.
+func EncodeLevels(w io.Writer, levels []image.Image, opts EncodeOptions) error {
+	img, err := Compress(levels, opts.Format, opts.Quality)
+	if err != nil {
+		return err
+	}
+	return Write(w, img)
+}
+
+// Compress compresses the mipmap levels into an image. Each level
+// must be half the size of the previous one, rounded down but at
+// least 1.
+//
+// In BC1 pixels with alpha below 128 become transparent,
+// BC3 keeps the interpolated alpha.
+func Compress(levels []image.Image, format DXGIFormat, quality Quality) (*Image, error) {
+	var encodeBlock func(pixels *[16][4]byte, quality Quality, block []byte)
+	switch format {
+	case BC1_UNORM, BC1_UNORM_SRGB:
+		encodeBlock = encodeBC1
+	case BC3_UNORM, BC3_UNORM_SRGB:
+		encodeBlock = encodeBC3
+	default:
+		return nil, fmt.Errorf("Compressing to %v is not supported", format)
+	}
+	if len(levels) == 0 {
+		return nil, errors.New("No image data")
+	}
+
+	size := levels[0].Bounds().Size()
+	if size.X <= 0 || size.Y <= 0 || size.X > maxSize || size.Y > maxSize {
+		return nil, fmt.Errorf("Invalid size %dx%d", size.X, size.Y)
+	}
+	if max := texutil.MaxMipMapCount(size.X, size.Y, 1); len(levels) > max {
+		return nil, fmt.Errorf("Too many mipmap levels %d for %dx%d, expected at most %d", len(levels), size.X, size.Y, max)
+	}
+
+	img := &Image{
+		Format:     fourCCFormat(format),
+		DXGIFormat: format,
+		Width:      size.X,
+		Height:     size.Y,
+		Depth:      1,
+		ArraySize:  1,
+	}
+
+	blockSize := format.BlockSize()
+	width, height := size.X, size.Y
+	for level, m := range levels {
+		if size := m.Bounds().Size(); size.X != width || size.Y != height {
+			return nil, fmt.Errorf("Mipmap level %d is %dx%d, expected %dx%d", level, size.X, size.Y, width, height)
+		}
+
+		src := toNRGBA(m)
+		data := make([]byte, format.LevelSize(width, height))
+		block := data
+
+		var pixels [16][4]byte
+		for by := 0; by < (height+3)/4; by++ {
+			for bx := 0; bx < (width+3)/4; bx++ {
+				blockPixels(src, bx, by, &pixels)
+				encodeBlock(&pixels, quality, block[:blockSize])
+				block = block[blockSize:]
+			}
+		}
+
+		img.Levels = append(img.Levels, Level{
+			Width:  width,
+			Height: height,
+			Depth:  1,
+			Data:   data,
+		})
+		width, height, _ = texutil.NextLevel(width, height, 1)
+	}
+
+	return img, nil
+}
+
+// fourCCFormat returns the FourCC code Write uses for format
+func fourCCFormat(format DXGIFormat) Format {
+	if fourCC, ok := legacyFourCC[format]; ok {
+		return fourCC
+	}
+	return DX10
+}
+
+// toNRGBA converts m to non-premultiplied RGBA with the origin at 0, 0
+func toNRGBA(m image.Image) *image.NRGBA {
+	if nrgba, ok := m.(*image.NRGBA); ok && nrgba.Rect.Min == (image.Point{}) {
+		return nrgba
+	}
+	bounds := m.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(nrgba, nrgba.Rect, m, bounds.Min, draw.Src)
+	return nrgba
+}
+
+// blockPixels copies the 4x4 block at bx, by, replicating
+// the edge pixels for blocks that do not fit into m
+func blockPixels(m *image.NRGBA, bx, by int, dst *[16][4]byte) {
+	for i := range dst {
+		x, y := bx*4+i%4, by*4+i/4
+		if x >= m.Rect.Dx() {
+			x = m.Rect.Dx() - 1
+		}
+		if y >= m.Rect.Dy() {
+			y = m.Rect.Dy() - 1
+		}
+		copy(dst[i][:], m.Pix[m.PixOffset(x, y):])
+	}
+}
+
+// encodeBC1 encodes a block with 1-bit alpha
+func encodeBC1(pixels *[16][4]byte, quality Quality, block []byte) {
+	encodeColors(pixels, quality, true, block)
+}
+
+// encodeBC3 encodes a block with interpolated alpha
+func encodeBC3(pixels *[16][4]byte, quality Quality, block []byte) {
+	var alpha [16]byte
+	for i := range pixels {
+		alpha[i] = pixels[i][3]
+	}
+	encodeChannel(&alpha, quality, block[:8])
+	encodeColors(pixels, quality, false, block[8:])
+}
+
+// endpoints are a pair of colors to interpolate between
+type endpoints [2][3]float32
+
+// encodeColors encodes the color part of BC1, BC2 and BC3 blocks,
+// when punchthrough is true pixels with alpha below 128 are
+// encoded as transparent in the three color mode
+func encodeColors(pixels *[16][4]byte, quality Quality, punchthrough bool, block []byte) {
+	var opaque [16]bool
+	var colors [][3]float32
+	for i, pixel := range pixels {
+		if punchthrough && pixel[3] < 128 {
+			continue
+		}
+		opaque[i] = true
+		colors = append(colors, [3]float32{float32(pixel[0]), float32(pixel[1]), float32(pixel[2])})
+	}
+
+	if len(colors) == 0 {
+		binary.LittleEndian.PutUint16(block[0:], 0)
+		binary.LittleEndian.PutUint16(block[2:], 0)
+		binary.LittleEndian.PutUint32(block[4:], 0xFFFFFFFF)
+		return
+	}
+
+	// the three color mode is needed for transparent pixels,
+	// for opaque blocks the best quality tries both modes
+	threeColors := len(colors) < len(pixels)
+	modes := []bool{threeColors}
+	if quality >= QualityBest && punchthrough && !threeColors {
+		modes = append(modes, true)
+	}
+
+	var ends endpoints
+	switch quality {
+	case QualityFast:
+		ends = boundingBox(colors)
+	default:
+		ends = principalAxis(colors)
+	}
+
+	best := colorFit{err: math.MaxInt64}
+	for _, mode := range modes {
+		fit := fitColors(pixels, &opaque, ends, mode)
+		if quality >= QualityBest {
+			for iteration := 0; iteration < 2; iteration++ {
+				refined, ok := fit.refine(pixels, &opaque)
+				if !ok {
+					break
+				}
+				next := fitColors(pixels, &opaque, refined, mode)
+				if next.err >= fit.err {
+					break
+				}
+				fit = next
+			}
+		}
+		if fit.err < best.err {
+			best = fit
+		}
+	}
+
+	binary.LittleEndian.PutUint16(block[0:], best.c0)
+	binary.LittleEndian.PutUint16(block[2:], best.c1)
+	binary.LittleEndian.PutUint32(block[4:], best.indices)
+}
+
+// boundingBox returns the corners of the bounding box of colors,
+// inset slightly since the extremes are rarely representative
+func boundingBox(colors [][3]float32) endpoints {
+	ends := endpoints{colors[0], colors[0]}
+	for _, c := range colors[1:] {
+		for k := range c {
+			ends[0][k] = float32(math.Max(float64(ends[0][k]), float64(c[k])))
+			ends[1][k] = float32(math.Min(float64(ends[1][k]), float64(c[k])))
+		}
+	}
+
+	// channels that decrease while the channel with the largest
+	// variance increases use the other diagonal of the box
+	_, cov := covariance(colors)
+	axis := largestVariance(&cov)
+	for k := 0; k < 3; k++ {
+		if cov[axis][k] < 0 {
+			ends[0][k], ends[1][k] = ends[1][k], ends[0][k]
+		}
+	}
+
+	for k := 0; k < 3; k++ {
+		inset := (ends[0][k] - ends[1][k]) / 16
+		ends[0][k] -= inset
+		ends[1][k] += inset
+	}
+	return ends
+}
+
+// principalAxis returns the extreme colors along the direction
+// of the largest variance
+func principalAxis(colors [][3]float32) endpoints {
+	mean, cov := covariance(colors)
+
+	// power iteration converges to the eigenvector with the largest
+	// eigenvalue, starting from the row of the channel with the
+	// largest variance, which is never orthogonal to it
+	row := largestVariance(&cov)
+	if cov[row][row] == 0 {
+		return endpoints{mean, mean}
+	}
+	axis := cov[row]
+	for iteration := 0; iteration < 8; iteration++ {
+		var next [3]float32
+		for i := 0; i < 3; i++ {
+			next[i] = cov[i][0]*axis[0] + cov[i][1]*axis[1] + cov[i][2]*axis[2]
+		}
+		length := float32(math.Sqrt(float64(next[0]*next[0] + next[1]*next[1] + next[2]*next[2])))
+		if length < 1e-6 {
+			break
+		}
+		for i := range next {
+			axis[i] = next[i] / length
+		}
+	}
+
+	lo, hi := float32(math.MaxFloat32), float32(-math.MaxFloat32)
+	var ends endpoints
+	for _, c := range colors {
+		t := (c[0]-mean[0])*axis[0] + (c[1]-mean[1])*axis[1] + (c[2]-mean[2])*axis[2]
+		if t > hi {
+			hi, ends[0] = t, c
+		}
+		if t < lo {
+			lo, ends[1] = t, c
+		}
+	}
+	return ends
+}
+
+// covariance returns the mean and the covariance matrix of colors
+func covariance(colors [][3]float32) (mean [3]float32, cov [3][3]float32) {
+	for _, c := range colors {
+		for k := range c {
+			mean[k] += c[k]
+		}
+	}
+	for k := range mean {
+		mean[k] /= float32(len(colors))
+	}
+
+	for _, c := range colors {
+		d := [3]float32{c[0] - mean[0], c[1] - mean[1], c[2] - mean[2]}
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				cov[i][j] += d[i] * d[j]
+			}
+		}
+	}
+	return mean, cov
+}
+
+// largestVariance returns the channel with the largest variance
+func largestVariance(cov *[3][3]float32) int {
+	largest := 0
+	for k := 1; k < 3; k++ {
+		if cov[k][k] > cov[largest][largest] {
+			largest = k
+		}
+	}
+	return largest
+}
+
+// colorFit is an encoded color block and its squared error
+type colorFit struct {
+	c0, c1      uint16
+	indices     uint32
+	threeColors bool
+	err         int
+}
+
+// fitColors quantizes the endpoints and selects the closest
+// palette entry for each opaque pixel
+func fitColors(pixels *[16][4]byte, opaque *[16]bool, ends endpoints, threeColors bool) colorFit {
+	fit := colorFit{
+		c0:          pack565(ends[0]),
+		c1:          pack565(ends[1]),
+		threeColors: threeColors,
+	}
+
+	// the order of the endpoints selects the mode
+	if threeColors && fit.c0 > fit.c1 || !threeColors && fit.c0 < fit.c1 {
+		fit.c0, fit.c1 = fit.c1, fit.c0
+	}
+
+	palette := colorPalette(fit.c0, fit.c1, !threeColors)
+	choices := 4
+	if threeColors {
+		choices = 3
+	}
+
+	for i, pixel := range pixels {
+		if !opaque[i] {
+			fit.indices |= 3 << (2 * uint(i))
+			continue
+		}
+
+		best, bestErr := 0, math.MaxInt32
+		for j := 0; j < choices; j++ {
+			err := 0
+			for k := 0; k < 3; k++ {
+				d := int(pixel[k]) - int(palette[j][k])
+				err += d * d
+			}
+			if err < bestErr {
+				best, bestErr = j, err
+			}
+		}
+		fit.indices |= uint32(best) << (2 * uint(i))
+		fit.err += bestErr
+	}
+	return fit
+}
+
+// refine solves for the endpoints that minimize the squared error
+// with the current indices, ok is false when they are degenerate
+func (fit colorFit) refine(pixels *[16][4]byte, opaque *[16]bool) (ends endpoints, ok bool) {
+	weights := [4]float32{1, 0, 2.0 / 3, 1.0 / 3}
+	if fit.threeColors {
+		weights = [4]float32{1, 0, 0.5, 0}
+	}
+
+	var aa, bb, ab float32
+	var ax, bx [3]float32
+	for i, pixel := range pixels {
+		if !opaque[i] {
+			continue
+		}
+		a := weights[fit.indices>>(2*uint(i))&3]
+		b := 1 - a
+		aa += a * a
+		bb += b * b
+		ab += a * b
+		for k := 0; k < 3; k++ {
+			ax[k] += a * float32(pixel[k])
+			bx[k] += b * float32(pixel[k])
+		}
+	}
+
+	det := aa*bb - ab*ab
+	if math.Abs(float64(det)) < 1e-6 {
+		return ends, false
+	}
+	for k := 0; k < 3; k++ {
+		ends[0][k] = clamp255((ax[k]*bb - bx[k]*ab) / det)
+		ends[1][k] = clamp255((bx[k]*aa - ax[k]*ab) / det)
+	}
+	return ends, true
+}
+
+// pack565 rounds a color to R5G6B5
+func pack565(c [3]float32) uint16 {
+	r := uint16(clamp255(c[0])*31/255 + 0.5)
+	g := uint16(clamp255(c[1])*63/255 + 0.5)
+	b := uint16(clamp255(c[2])*31/255 + 0.5)
+	return r<<11 | g<<5 | b
+}
+
+func clamp255(v float32) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return v
+}
+
+// encodeChannel encodes a BC4 style block, the eight value mode
+// spans the range of values, the best quality also tries the
+// six value mode which has exact 0 and 255
+func encodeChannel(values *[16]byte, quality Quality, block []byte) {
+	lo, hi := 255, 0
+	inner := [2]int{255, 0}
+	for _, v := range values {
+		v := int(v)
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+		if v != 0 && v != 255 {
+			if v < inner[0] {
+				inner[0] = v
+			}
+			if v > inner[1] {
+				inner[1] = v
+			}
+		}
+	}
+
+	candidates := [][2]int{{hi, lo}}
+	if quality >= QualityBest && (lo == 0 || hi == 255) {
+		if inner[0] > inner[1] {
+			inner = [2]int{0, 0}
+		}
+		candidates = append(candidates, [2]int{inner[0], inner[1]})
+	}
+
+	bestErr := math.MaxInt32
+	for _, c := range candidates {
+		palette := channelPalette(c[0], c[1])
+
+		var bits uint64
+		err := 0
+		for i, v := range values {
+			best, bestDist := 0, math.MaxInt32
+			for j, p := range palette {
+				d := (int(v) - p) * (int(v) - p)
+				if d < bestDist {
+					best, bestDist = j, d
+				}
+			}
+			bits |= uint64(best) << (3 * uint(i))
+			err += bestDist
+		}
+
+		if err < bestErr {
+			bestErr = err
+			block[0], block[1] = byte(c[0]), byte(c[1])
+			for k := 2; k < 8; k++ {
+				block[k] = byte(bits >> (8 * uint(k-2)))
+			}
+		}
+	}
+}
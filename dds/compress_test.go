@@ -0,0 +1,100 @@
+package dds
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func checker(a, b color.NRGBA) *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if (x+y)%2 == 0 {
+				m.SetNRGBA(x, y, a)
+			} else {
+				m.SetNRGBA(x, y, b)
+			}
+		}
+	}
+	return m
+}
+
+// gradient has red increasing and green decreasing from left to right
+func gradient() *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			m.SetNRGBA(x, y, color.NRGBA{uint8(x * 32), uint8(255 - x*32), 128, 255})
+		}
+	}
+	return m
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	green := color.NRGBA{0, 255, 0, 255}
+	blue := color.NRGBA{0, 0, 255, 255}
+	yellow := color.NRGBA{255, 255, 0, 255}
+
+	tests := []struct {
+		name      string
+		img       *image.NRGBA
+		tolerance int
+	}{
+		{"solid", checker(red, red), 0},
+		{"red green", checker(red, green), 0},
+		{"blue yellow", checker(blue, yellow), 0},
+		{"gradient", gradient(), 12},
+	}
+
+	for _, format := range []DXGIFormat{BC1_UNORM, BC3_UNORM} {
+		for _, quality := range []Quality{QualityFast, QualityNormal, QualityBest} {
+			for _, test := range tests {
+				var buf bytes.Buffer
+				opts := EncodeOptions{Format: format, Quality: quality}
+				if err := Encode(&buf, test.img, opts); err != nil {
+					t.Fatalf("%v %v %v: %v", format, quality, test.name, err)
+				}
+
+				decoded, kind, err := image.Decode(&buf)
+				if err != nil {
+					t.Fatalf("%v %v %v: %v", format, quality, test.name, err)
+				}
+				if kind != "dds" || decoded.Bounds() != test.img.Bounds() {
+					t.Fatalf("%v %v %v: decoded %v %v", format, quality, test.name, kind, decoded.Bounds())
+				}
+
+				// the bounding box is inset by 1/16 of its size
+				tolerance := test.tolerance
+				if quality == QualityFast {
+					tolerance += 16
+				}
+
+				bounds := test.img.Bounds()
+				for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+					for x := bounds.Min.X; x < bounds.Max.X; x++ {
+						want := test.img.NRGBAAt(x, y)
+						got := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+						if !closeColor(got, want, tolerance) {
+							t.Errorf("%v %v %v: pixel %d,%d is %v, expected %v", format, quality, test.name, x, y, got, want)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func closeColor(a, b color.NRGBA, tolerance int) bool {
+	for _, d := range []int{
+		int(a.R) - int(b.R), int(a.G) - int(b.G),
+		int(a.B) - int(b.B), int(a.A) - int(b.A),
+	} {
+		if d < -tolerance || d > tolerance {
+			return false
+		}
+	}
+	return true
+}
@@ -8,102 +8,351 @@ import (
 	"io"
 	"os"
 
-	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/egonelbre/opengl-tutorial.org/internal/texutil"
 )
 
+// Format is the FourCC code of the pixel format.
 type Format uint32
 
+func (format Format) String() string {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(format))
+	return string(b[:])
+}
+
+// Header flags
+const (
+	FlagCaps        = 0x1
+	FlagHeight      = 0x2
+	FlagWidth       = 0x4
+	FlagPitch       = 0x8
+	FlagPixelFormat = 0x1000
+	FlagMipMapCount = 0x20000
+	FlagLinearSize  = 0x80000
+	FlagDepth       = 0x800000
+)
+
+// Pixel format flags
+const (
+	PixelAlphaPixels = 0x1
+	PixelAlpha       = 0x2
+	PixelFourCC      = 0x4
+	PixelRGB         = 0x40
+	PixelLuminance   = 0x20000
+)
+
+const (
+	headerSize      = 124
+	pixelFormatSize = 32
+)
+
+// Caps2 flags
 const (
-	DXT1 = Format(0x31545844)
-	DXT3 = Format(0x33545844)
-	DXT5 = Format(0x35545844)
+	Caps2Cubemap = 0x200
+	Caps2Volume  = 0x200000
+
+	// Caps2CubemapAllFaces are the flags for the six cubemap faces
+	Caps2CubemapAllFaces = 0xFC00
 )
 
-func LoadFile(filename string) (uint32, error) {
+// DX10 header resource dimensions and misc flags
+const (
+	DimensionTexture2D = 3
+	DimensionTexture3D = 4
+
+	MiscTextureCube = 0x4
+)
+
+const dx10HeaderSize = 20
+
+// Image is a decoded DDS file.
+//
+// Format is the FourCC code from the header, DXGIFormat the
+// corresponding DXGI format. For files with the DX10 header
+// Format is DX10 and DXGIFormat comes from the extended header.
+// Uncompressed files without a FourCC code are converted to
+// R8G8B8A8_UNORM and their Format is 0.
+//
+// Depth is larger than 1 for volume textures, ArraySize for
+// texture arrays. Cubemaps have six faces for each array layer.
+type Image struct {
+	Format     Format
+	DXGIFormat DXGIFormat
+	Width      int
+	Height     int
+	Depth      int
+	ArraySize  int
+	Cubemap    bool
+
+	// Flags contains the DDSD_* header flags
+	Flags uint32
+
+	// GLInternalFormat, GLFormat and GLType are set for images from
+	// other containers, such as KTX, whose format may not have a
+	// DXGI equivalent; GLFormat is 0 for compressed formats
+	GLInternalFormat uint32
+	GLFormat         uint32
+	GLType           uint32
+
+	// Levels contains the mipmap levels, starting with the largest
+	Levels []Level
+}
+
+// Level is a single mipmap level.
+//
+// Data contains all the layers of the level one after another,
+// see Image.Surface.
+type Level struct {
+	Width  int
+	Height int
+	Depth  int
+	Data   []byte
+}
+
+// Layers returns the number of 2D surfaces or volumes in a level,
+// i.e. ArraySize for texture arrays and 6*ArraySize for cubemaps.
+func (img *Image) Layers() int {
+	if img.Cubemap {
+		return 6 * img.ArraySize
+	}
+	return img.ArraySize
+}
+
+// Surface returns the data of layer at level.
+//
+// For cubemaps, layer is 6*arrayIndex + face, where the faces
+// are in order +X, -X, +Y, -Y, +Z, -Z.
+func (img *Image) Surface(level, layer int) []byte {
+	data := img.Levels[level].Data
+	size := len(data) / img.Layers()
+	return data[layer*size : (layer+1)*size]
+}
+
+func DecodeFile(filename string) (*Image, error) {
 	file, err := os.Open(filename)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	defer file.Close()
 
-	return Load(bufio.NewReader(file))
+	return Decode(bufio.NewReader(file))
+}
+
+// Decode parses a DDS file without uploading it.
+func Decode(r io.Reader) (*Image, error) {
+	img, layout, err := decodeHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := img.readLevels(r, layout); err != nil {
+		return nil, err
+	}
+	return img, nil
 }
 
-func Load(r io.Reader) (uint32, error) {
+// layout describes how the data is stored in the file
+type layout struct {
+	mipMapCount int
+	// masked is the format of uncompressed files, nil otherwise
+	masked *maskFormat
+}
+
+// decodeHeader parses and validates the headers,
+// the returned image does not contain any levels
+func decodeHeader(r io.Reader) (*Image, layout, error) {
 	var err error
+	var none layout
 
 	var magic [4]byte
 
 	_, err = io.ReadFull(r, magic[:])
 	if err != nil {
-		return 0, err
+		return nil, none, err
 	}
 	if string(magic[:]) != "DDS " {
-		return 0, errors.New("Not DDS file")
+		return nil, none, errors.New("Not DDS file")
 	}
 
-	var buf [124]byte
+	var buf [headerSize]byte
 	_, err = io.ReadFull(r, buf[:])
 	if err != nil {
-		return 0, err
+		return nil, none, fmt.Errorf("Truncated header: %v", err)
 	}
 
 	enc := binary.LittleEndian
 
-	height := int32(enc.Uint32(buf[8:]))
-	width := int32(enc.Uint32(buf[12:]))
-	linearSize := enc.Uint32(buf[16:])
-	mipMapCount := int32(enc.Uint32(buf[24:]))
-	fourCC := Format(enc.Uint32(buf[80:]))
+	size := enc.Uint32(buf[0:])
+	if size != headerSize {
+		return nil, none, fmt.Errorf("Invalid header size %d, expected %d", size, headerSize)
+	}
+	pixelSize := enc.Uint32(buf[72:])
+	if pixelSize != pixelFormatSize {
+		return nil, none, fmt.Errorf("Invalid pixel format size %d, expected %d", pixelSize, pixelFormatSize)
+	}
+
+	img := &Image{}
+	img.Flags = enc.Uint32(buf[4:])
+	img.Height = int(enc.Uint32(buf[8:]))
+	img.Width = int(enc.Uint32(buf[12:]))
+	linearSize := int(enc.Uint32(buf[16:]))
+	img.Depth = int(enc.Uint32(buf[20:]))
+	mipMapCount := int(enc.Uint32(buf[24:]))
+	pixelFlags := enc.Uint32(buf[76:])
+	img.Format = Format(enc.Uint32(buf[80:]))
+	caps2 := enc.Uint32(buf[108:])
+
+	img.ArraySize = 1
+	img.Cubemap = caps2&Caps2Cubemap != 0
+	volume := caps2&Caps2Volume != 0
 
-	bufsize := linearSize
-	if mipMapCount > 1 {
-		bufsize = 2 * linearSize
+	if img.Flags&(FlagWidth|FlagHeight) != FlagWidth|FlagHeight {
+		return nil, none, fmt.Errorf("Missing width or height flag in 0x%x", img.Flags)
 	}
+	if img.Width <= 0 || img.Height <= 0 || img.Width > maxSize || img.Height > maxSize {
+		return nil, none, fmt.Errorf("Invalid size %dx%d", img.Width, img.Height)
+	}
+
+	// uncompressed files are converted to R8G8B8A8_UNORM
+	var masked *maskFormat
+	if pixelFlags&PixelFourCC == 0 {
+		img.Format = 0
+		img.DXGIFormat = R8G8B8A8_UNORM
+		masked, err = parseMaskFormat(pixelFlags, buf[84:104])
+		if err != nil {
+			return nil, none, err
+		}
+	} else if img.Format == DX10 {
+		var ext [dx10HeaderSize]byte
+		_, err = io.ReadFull(r, ext[:])
+		if err != nil {
+			return nil, none, fmt.Errorf("Truncated DX10 header: %v", err)
+		}
 
-	buffer := make([]byte, bufsize)
-	n, _ := io.ReadFull(r, buffer)
-	buffer = buffer[:n]
+		img.DXGIFormat = DXGIFormat(enc.Uint32(ext[0:]))
+		dimension := enc.Uint32(ext[4:])
+		miscFlag := enc.Uint32(ext[8:])
+		img.ArraySize = int(enc.Uint32(ext[12:]))
 
-	format := uint32(0)
-	switch fourCC {
-	case DXT1:
-		format = gl.COMPRESSED_RGBA_S3TC_DXT1_EXT
-	case DXT3:
-		format = gl.COMPRESSED_RGBA_S3TC_DXT3_EXT
-	case DXT5:
-		format = gl.COMPRESSED_RGBA_S3TC_DXT5_EXT
-	default:
-		return 0, fmt.Errorf("Unimplemented format 0x%x", fourCC)
+		switch dimension {
+		case DimensionTexture2D:
+			img.Cubemap = miscFlag&MiscTextureCube != 0
+			volume = false
+		case DimensionTexture3D:
+			img.Cubemap = false
+			volume = true
+		default:
+			return nil, none, fmt.Errorf("Unimplemented resource dimension %d", dimension)
+		}
+		if img.ArraySize <= 0 || img.ArraySize > maxSize {
+			return nil, none, fmt.Errorf("Invalid array size %d", img.ArraySize)
+		}
+		if volume && img.ArraySize > 1 {
+			return nil, none, errors.New("Volume texture arrays are not supported")
+		}
+	} else {
+		img.DXGIFormat = fourCCFormats[img.Format]
 	}
 
-	var textureID uint32
-	gl.GenTextures(1, &textureID)
+	if img.DXGIFormat.LevelSize(1, 1) == 0 {
+		if img.Format == DX10 {
+			return nil, none, fmt.Errorf("Unimplemented format %v", img.DXGIFormat)
+		}
+		return nil, none, fmt.Errorf("Unimplemented format 0x%x", uint32(img.Format))
+	}
 
-	gl.BindTexture(gl.TEXTURE_2D, textureID)
-	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	if img.Cubemap {
+		if img.Format != DX10 && caps2&Caps2CubemapAllFaces != Caps2CubemapAllFaces {
+			return nil, none, fmt.Errorf("Cubemap does not contain all faces, caps2 0x%x", caps2)
+		}
+		if img.Width != img.Height {
+			return nil, none, fmt.Errorf("Cubemap faces are not square %dx%d", img.Width, img.Height)
+		}
+	}
 
-	blockSize := int32(8)
-	if fourCC != DXT1 {
-		blockSize = 16
+	if volume {
+		if img.Flags&FlagDepth == 0 || img.Depth <= 0 || img.Depth > maxSize {
+			return nil, none, fmt.Errorf("Invalid volume depth %d", img.Depth)
+		}
+	} else {
+		img.Depth = 1
 	}
-	offset := 0
 
-	for level := int32(0); level < mipMapCount && (width > 0 || height > 0); level++ {
-		size := ((width + 3) / 4) * ((height + 3) / 4) * blockSize
-		gl.CompressedTexImage2D(gl.TEXTURE_2D, level, format, width, height, 0, size, gl.Ptr(buffer[offset:]))
+	if img.Flags&FlagMipMapCount == 0 || mipMapCount == 0 {
+		mipMapCount = 1
+	}
+	if max := texutil.MaxMipMapCount(img.Width, img.Height, img.Depth); mipMapCount > max {
+		return nil, none, fmt.Errorf("Invalid mipmap count %d for %dx%dx%d, expected at most %d",
+			mipMapCount, img.Width, img.Height, img.Depth, max)
+	}
 
-		offset += int(size)
-		width /= 2
-		height /= 2
+	layout := layout{mipMapCount, masked}
 
-		if width < 1 {
-			width = 1
+	switch {
+	case img.Flags&FlagLinearSize != 0 && linearSize != 0:
+		if expected := layout.levelSize(img, img.Width, img.Height); linearSize != expected {
+			return nil, none, fmt.Errorf("Inconsistent linear size %d, expected %d", linearSize, expected)
 		}
-		if height < 1 {
-			height = 1
+	case img.Flags&FlagPitch != 0 && linearSize != 0 && masked != nil:
+		if expected := masked.rowSize(img.Width); linearSize != expected {
+			return nil, none, fmt.Errorf("Inconsistent pitch %d, expected %d", linearSize, expected)
 		}
 	}
 
-	return textureID, nil
+	return img, layout, nil
 }
+
+// levelSize returns the size of a level in the file
+func (layout layout) levelSize(img *Image, width, height int) int {
+	if layout.masked != nil {
+		return layout.masked.levelSize(width, height)
+	}
+	return img.DXGIFormat.LevelSize(width, height)
+}
+
+// readLevels reads the mipmap levels of all the layers
+func (img *Image) readLevels(r io.Reader, layout layout) error {
+	layers := img.Layers()
+
+	width, height, depth := img.Width, img.Height, img.Depth
+	for level := 0; level < layout.mipMapCount; level++ {
+		img.Levels = append(img.Levels, Level{
+			Width:  width,
+			Height: height,
+			Depth:  depth,
+		})
+		width, height, depth = texutil.NextLevel(width, height, depth)
+	}
+
+	// the file contains the full mipmap chain for each layer
+	for layer := 0; layer < layers; layer++ {
+		for level := range img.Levels {
+			dst := &img.Levels[level]
+			size := layout.levelSize(img, dst.Width, dst.Height) * dst.Depth
+
+			data, err := texutil.ReadData(r, size)
+			if n := len(data); err == io.EOF {
+				if layers > 1 {
+					return fmt.Errorf("Truncated layer %d mipmap level %d: expected %d bytes, got %d", layer, level, size, n)
+				}
+				return fmt.Errorf("Truncated mipmap level %d: expected %d bytes, got %d", level, size, n)
+			}
+			if err != nil {
+				return err
+			}
+			if layout.masked != nil {
+				data = layout.masked.toRGBA(data, dst.Width, dst.Height*dst.Depth)
+			}
+
+			if dst.Data == nil {
+				dst.Data = data
+			} else {
+				dst.Data = append(dst.Data, data...)
+			}
+		}
+	}
+
+	return nil
+}
+
+// maxSize is the maximum supported width or height
+const maxSize = 1 << 16
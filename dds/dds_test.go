@@ -0,0 +1,427 @@
+package dds
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/egonelbre/opengl-tutorial.org/internal/texutil"
+)
+
+// testHeader describes a DDS file header for building test files,
+// zero sizes default to the valid values
+type testHeader struct {
+	size            uint32
+	flags           uint32
+	width, height   int
+	depth           int
+	linearSize      uint32
+	mipMapCount     int
+	pixelFormatSize uint32
+	pixelFlags      uint32
+	fourCC          Format
+	bitCount        uint32
+	masks           [4]uint32 // red, green, blue, alpha
+	caps2           uint32
+
+	// dx10 is DXGI format, dimension, misc flag, array size and misc flags 2
+	dx10 []uint32
+}
+
+func (h testHeader) file(data ...[]byte) []byte {
+	enc := binary.LittleEndian
+	if h.size == 0 {
+		h.size = headerSize
+	}
+	if h.pixelFormatSize == 0 {
+		h.pixelFormatSize = pixelFormatSize
+	}
+
+	buf := make([]byte, 4+headerSize)
+	copy(buf, "DDS ")
+	hdr := buf[4:]
+	enc.PutUint32(hdr[0:], h.size)
+	enc.PutUint32(hdr[4:], h.flags)
+	enc.PutUint32(hdr[8:], uint32(h.height))
+	enc.PutUint32(hdr[12:], uint32(h.width))
+	enc.PutUint32(hdr[16:], h.linearSize)
+	enc.PutUint32(hdr[20:], uint32(h.depth))
+	enc.PutUint32(hdr[24:], uint32(h.mipMapCount))
+	enc.PutUint32(hdr[72:], h.pixelFormatSize)
+	enc.PutUint32(hdr[76:], h.pixelFlags)
+	enc.PutUint32(hdr[80:], uint32(h.fourCC))
+	enc.PutUint32(hdr[84:], h.bitCount)
+	for k, mask := range h.masks {
+		enc.PutUint32(hdr[88+4*k:], mask)
+	}
+	enc.PutUint32(hdr[104:], CapsTexture)
+	enc.PutUint32(hdr[108:], h.caps2)
+
+	for _, v := range h.dx10 {
+		buf = append(buf, 0, 0, 0, 0)
+		enc.PutUint32(buf[len(buf)-4:], v)
+	}
+	for _, d := range data {
+		buf = append(buf, d...)
+	}
+	return buf
+}
+
+const baseFlags = FlagCaps | FlagHeight | FlagWidth | FlagPixelFormat
+
+func fourCCHeader(format Format, width, height int) testHeader {
+	return testHeader{
+		flags:      baseFlags,
+		width:      width,
+		height:     height,
+		pixelFlags: PixelFourCC,
+		fourCC:     format,
+	}
+}
+
+func dx10Header(format DXGIFormat, width, height, dimension, misc, arraySize int) testHeader {
+	h := fourCCHeader(DX10, width, height)
+	h.dx10 = []uint32{uint32(format), uint32(dimension), uint32(misc), uint32(arraySize), 0}
+	return h
+}
+
+func maskHeader(pixelFlags, bitCount uint32, masks [4]uint32, width, height int) testHeader {
+	return testHeader{
+		flags:      baseFlags,
+		width:      width,
+		height:     height,
+		pixelFlags: pixelFlags,
+		bitCount:   bitCount,
+		masks:      masks,
+	}
+}
+
+// sequence returns n bytes counting up from start
+func sequence(start byte, n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = start + byte(i)
+	}
+	return data
+}
+
+func with(h testHeader, change func(h *testHeader)) testHeader {
+	change(&h)
+	return h
+}
+
+func TestDecode(t *testing.T) {
+	mipmapped := fourCCHeader(DXT1, 8, 8)
+	mipmapped.flags |= FlagMipMapCount
+	mipmapped.mipMapCount = 4
+
+	cube := fourCCHeader(DXT5, 4, 4)
+	cube.caps2 = Caps2Cubemap | Caps2CubemapAllFaces
+
+	volume := maskHeader(PixelRGB|PixelAlphaPixels, 32, [4]uint32{0xFF, 0xFF00, 0xFF0000, 0xFF000000}, 2, 2)
+	volume.flags |= FlagDepth | FlagMipMapCount
+	volume.depth = 2
+	volume.mipMapCount = 2
+	volume.caps2 = Caps2Volume
+
+	type level struct{ width, height, depth, size int }
+	tests := []struct {
+		name   string
+		file   []byte
+		format DXGIFormat
+		layers int
+		levels []level
+	}{
+		{
+			name:   "DXT1",
+			file:   fourCCHeader(DXT1, 4, 4).file(sequence(0, 8)),
+			format: BC1_UNORM, layers: 1,
+			levels: []level{{4, 4, 1, 8}},
+		},
+		{
+			name:   "DXT1 ignores mipmap count without flag",
+			file:   with(fourCCHeader(DXT1, 4, 4), func(h *testHeader) { h.mipMapCount = 3 }).file(sequence(0, 8)),
+			format: BC1_UNORM, layers: 1,
+			levels: []level{{4, 4, 1, 8}},
+		},
+		{
+			name:   "DXT1 mipmaps",
+			file:   mipmapped.file(sequence(0, 32), sequence(32, 8), sequence(40, 8), sequence(48, 8)),
+			format: BC1_UNORM, layers: 1,
+			levels: []level{{8, 8, 1, 32}, {4, 4, 1, 8}, {2, 2, 1, 8}, {1, 1, 1, 8}},
+		},
+		{
+			name:   "non power of two",
+			file:   fourCCHeader(ATI2, 5, 3).file(sequence(0, 2*16)),
+			format: BC5_UNORM, layers: 1,
+			levels: []level{{5, 3, 1, 32}},
+		},
+		{
+			name:   "DXT5 cubemap",
+			file:   cube.file(sequence(0, 6*16)),
+			format: BC3_UNORM, layers: 6,
+			levels: []level{{4, 4, 1, 6 * 16}},
+		},
+		{
+			name:   "DX10 array",
+			file:   dx10Header(BC7_UNORM, 4, 4, DimensionTexture2D, 0, 3).file(sequence(0, 3*16)),
+			format: BC7_UNORM, layers: 3,
+			levels: []level{{4, 4, 1, 3 * 16}},
+		},
+		{
+			name:   "DX10 cubemap array",
+			file:   dx10Header(BC1_UNORM_SRGB, 4, 4, DimensionTexture2D, MiscTextureCube, 2).file(sequence(0, 12*8)),
+			format: BC1_UNORM_SRGB, layers: 12,
+			levels: []level{{4, 4, 1, 12 * 8}},
+		},
+		{
+			name:   "DX10 volume",
+			file:   with(dx10Header(R8G8B8A8_UNORM, 2, 2, DimensionTexture3D, 0, 1), func(h *testHeader) { h.flags |= FlagDepth; h.depth = 3 }).file(sequence(0, 2*2*3*4)),
+			format: R8G8B8A8_UNORM, layers: 1,
+			levels: []level{{2, 2, 3, 48}},
+		},
+		{
+			name:   "volume mipmaps",
+			file:   volume.file(sequence(0, 32), sequence(32, 4)),
+			format: R8G8B8A8_UNORM, layers: 1,
+			levels: []level{{2, 2, 2, 32}, {1, 1, 1, 4}},
+		},
+		{
+			name:   "linear size",
+			file:   with(fourCCHeader(DXT3, 8, 4), func(h *testHeader) { h.flags |= FlagLinearSize; h.linearSize = 32 }).file(sequence(0, 32)),
+			format: BC2_UNORM, layers: 1,
+			levels: []level{{8, 4, 1, 32}},
+		},
+	}
+
+	for _, test := range tests {
+		img, err := Decode(bytes.NewReader(test.file))
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if img.DXGIFormat != test.format || img.Layers() != test.layers {
+			t.Errorf("%v: got %v with %d layers, expected %v with %d", test.name, img.DXGIFormat, img.Layers(), test.format, test.layers)
+		}
+
+		var got []level
+		var data []byte
+		for _, l := range img.Levels {
+			got = append(got, level{l.Width, l.Height, l.Depth, len(l.Data)})
+			data = append(data, l.Data...)
+		}
+		if !reflect.DeepEqual(got, test.levels) {
+			t.Errorf("%v: got levels %v, expected %v", test.name, got, test.levels)
+		}
+
+		// single layer files keep the data as is
+		if test.layers == 1 && test.format != R8G8B8A8_UNORM {
+			if !bytes.HasSuffix(test.file, data) {
+				t.Errorf("%v: data differs from the file", test.name)
+			}
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	dxt1 := fourCCHeader(DXT1, 4, 4)
+	rgba := [4]uint32{0xFF0000, 0xFF00, 0xFF, 0xFF000000}
+
+	tests := []struct {
+		name string
+		file []byte
+		err  string
+	}{
+		{"magic", append([]byte("DDX "), dxt1.file()[4:]...), "Not DDS file"},
+		{"truncated header", dxt1.file()[:60], "Truncated header"},
+		{"header size", with(dxt1, func(h *testHeader) { h.size = 100 }).file(), "Invalid header size 100"},
+		{"pixel format size", with(dxt1, func(h *testHeader) { h.pixelFormatSize = 24 }).file(), "Invalid pixel format size 24"},
+		{"missing width", with(dxt1, func(h *testHeader) { h.flags &^= FlagWidth }).file(), "Missing width or height flag"},
+		{"zero size", with(dxt1, func(h *testHeader) { h.width = 0 }).file(), "Invalid size 0x4"},
+		{"too large", with(dxt1, func(h *testHeader) { h.height = 1 << 20 }).file(), "Invalid size 4x1048576"},
+		{"unknown FourCC", fourCCHeader(Format(0x41424344), 4, 4).file(), "Unimplemented format 0x41424344"},
+		{"truncated DX10 header", dx10Header(BC1_UNORM, 4, 4, DimensionTexture2D, 0, 1).file()[:4+headerSize+8], "Truncated DX10 header"},
+		{"DX10 dimension", dx10Header(BC1_UNORM, 4, 4, 2, 0, 1).file(), "Unimplemented resource dimension 2"},
+		{"DX10 array size", dx10Header(BC1_UNORM, 4, 4, DimensionTexture2D, 0, 0).file(), "Invalid array size 0"},
+		{"DX10 volume array", dx10Header(BC1_UNORM, 4, 4, DimensionTexture3D, 0, 2).file(), "Volume texture arrays are not supported"},
+		{"DX10 format", dx10Header(BC6H_SF16+100, 4, 4, DimensionTexture2D, 0, 1).file(), "Unimplemented format"},
+		{"cubemap faces", with(dxt1, func(h *testHeader) { h.caps2 = Caps2Cubemap | 0x400 }).file(), "Cubemap does not contain all faces"},
+		{"cubemap square", with(dxt1, func(h *testHeader) { h.width = 8; h.caps2 = Caps2Cubemap | Caps2CubemapAllFaces }).file(), "Cubemap faces are not square 8x4"},
+		{"volume depth", with(dxt1, func(h *testHeader) { h.caps2 = Caps2Volume; h.flags |= FlagDepth }).file(), "Invalid volume depth 0"},
+		{"mipmap count", with(dxt1, func(h *testHeader) { h.flags |= FlagMipMapCount; h.mipMapCount = 4 }).file(), "Invalid mipmap count 4 for 4x4x1, expected at most 3"},
+		{"linear size", with(dxt1, func(h *testHeader) { h.flags |= FlagLinearSize; h.linearSize = 16 }).file(), "Inconsistent linear size 16, expected 8"},
+		{"pitch", with(maskHeader(PixelRGB, 32, rgba, 4, 4), func(h *testHeader) { h.flags |= FlagPitch; h.linearSize = 4 }).file(), "Inconsistent pitch 4, expected 16"},
+		{"bit count", maskHeader(PixelRGB, 12, rgba, 4, 4).file(), "Unimplemented bit count 12"},
+		{"color masks", maskHeader(PixelRGB, 32, [4]uint32{}, 4, 4).file(), "Missing color masks"},
+		{"pixel flags", maskHeader(0, 32, rgba, 4, 4).file(), "Unimplemented pixel format flags 0x0"},
+		{"truncated data", dxt1.file(sequence(0, 5)), "Truncated mipmap level 0: expected 8 bytes, got 5"},
+		{"truncated layer", dx10Header(BC1_UNORM, 4, 4, DimensionTexture2D, 0, 2).file(sequence(0, 12)), "Truncated layer 1 mipmap level 0: expected 8 bytes, got 4"},
+	}
+
+	for _, test := range tests {
+		_, err := Decode(bytes.NewReader(test.file))
+		if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("%v: got error %v, expected %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestDecodeMaskFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		pixelFlags uint32
+		bitCount   uint32
+		masks      [4]uint32
+		pixel      []byte
+		rgba       [4]byte
+	}{
+		{"A8R8G8B8", PixelRGB | PixelAlphaPixels, 32, [4]uint32{0xFF0000, 0xFF00, 0xFF, 0xFF000000}, []byte{0x30, 0x20, 0x10, 0x40}, [4]byte{0x10, 0x20, 0x30, 0x40}},
+		{"A8B8G8R8", PixelRGB | PixelAlphaPixels, 32, [4]uint32{0xFF, 0xFF00, 0xFF0000, 0xFF000000}, []byte{0x10, 0x20, 0x30, 0x40}, [4]byte{0x10, 0x20, 0x30, 0x40}},
+		{"X8R8G8B8", PixelRGB, 32, [4]uint32{0xFF0000, 0xFF00, 0xFF, 0xFF000000}, []byte{0x30, 0x20, 0x10, 0x40}, [4]byte{0x10, 0x20, 0x30, 0xFF}},
+		{"R8G8B8", PixelRGB, 24, [4]uint32{0xFF0000, 0xFF00, 0xFF, 0}, []byte{0x30, 0x20, 0x10}, [4]byte{0x10, 0x20, 0x30, 0xFF}},
+		{"R5G6B5", PixelRGB, 16, [4]uint32{0xF800, 0x07E0, 0x001F, 0}, []byte{0xE0, 0xFF}, [4]byte{0xFF, 0xFF, 0x00, 0xFF}},
+		{"A1R5G5B5", PixelRGB | PixelAlphaPixels, 16, [4]uint32{0x7C00, 0x03E0, 0x001F, 0x8000}, []byte{0x1F, 0x00}, [4]byte{0x00, 0x00, 0xFF, 0x00}},
+		{"A4R4G4B4", PixelRGB | PixelAlphaPixels, 16, [4]uint32{0x0F00, 0x00F0, 0x000F, 0xF000}, []byte{0x21, 0x43}, [4]byte{0x33, 0x22, 0x11, 0x44}},
+		{"L8", PixelLuminance, 8, [4]uint32{0xFF, 0, 0, 0}, []byte{0x80}, [4]byte{0x80, 0x80, 0x80, 0xFF}},
+		{"A8L8", PixelLuminance | PixelAlphaPixels, 16, [4]uint32{0xFF, 0, 0, 0xFF00}, []byte{0x80, 0x40}, [4]byte{0x80, 0x80, 0x80, 0x40}},
+		{"A8", PixelAlpha, 8, [4]uint32{0, 0, 0, 0xFF}, []byte{0x40}, [4]byte{0, 0, 0, 0x40}},
+	}
+
+	for _, test := range tests {
+		// a 2x1 image checks that pixels are not padded
+		data := append(append([]byte(nil), test.pixel...), test.pixel...)
+		file := maskHeader(test.pixelFlags, test.bitCount, test.masks, 2, 1).file(data)
+
+		img, err := Decode(bytes.NewReader(file))
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		expected := append(test.rgba[:], test.rgba[:]...)
+		if img.DXGIFormat != R8G8B8A8_UNORM || !bytes.Equal(img.Levels[0].Data, expected) {
+			t.Errorf("%v: got %v %v, expected %v", test.name, img.DXGIFormat, img.Levels[0].Data, expected)
+		}
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		img  *Image
+	}{
+		{"2D", &Image{DXGIFormat: BC1_UNORM, Width: 8, Height: 8, Depth: 1, ArraySize: 1}},
+		{"cube", &Image{DXGIFormat: BC3_UNORM, Width: 8, Height: 8, Depth: 1, ArraySize: 1, Cubemap: true}},
+		{"cube array", &Image{DXGIFormat: BC1_UNORM, Width: 4, Height: 4, Depth: 1, ArraySize: 2, Cubemap: true}},
+		{"array", &Image{DXGIFormat: BC7_UNORM_SRGB, Width: 8, Height: 4, Depth: 1, ArraySize: 3}},
+		{"volume", &Image{DXGIFormat: R8G8B8A8_UNORM, Width: 4, Height: 2, Depth: 4, ArraySize: 1}},
+		{"uncompressed", &Image{DXGIFormat: R8G8B8A8_UNORM_SRGB, Width: 3, Height: 5, Depth: 1, ArraySize: 1}},
+	}
+
+	for _, test := range tests {
+		img := test.img
+		width, height, depth := img.Width, img.Height, img.Depth
+		for level := 0; level < texutil.MaxMipMapCount(width, height, depth); level++ {
+			size := img.DXGIFormat.LevelSize(width, height) * depth * img.Layers()
+			img.Levels = append(img.Levels, Level{
+				Width:  width,
+				Height: height,
+				Depth:  depth,
+				Data:   sequence(byte(level*16), size),
+			})
+			width, height, depth = texutil.NextLevel(width, height, depth)
+		}
+
+		var buf bytes.Buffer
+		if err := Write(&buf, img); err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		decoded, err := Decode(&buf)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+
+		if decoded.DXGIFormat != img.DXGIFormat || decoded.Width != img.Width || decoded.Height != img.Height ||
+			decoded.Depth != img.Depth || decoded.ArraySize != img.ArraySize || decoded.Cubemap != img.Cubemap {
+			t.Errorf("%v: got %+v, expected %+v", test.name, decoded, img)
+			continue
+		}
+		if !reflect.DeepEqual(decoded.Levels, img.Levels) {
+			t.Errorf("%v: levels differ", test.name)
+		}
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	// pixel i uses index i%4, or i%8 for channel blocks
+	colorBlock := func(c0, c1 uint16) []byte {
+		block := make([]byte, 8)
+		binary.LittleEndian.PutUint16(block[0:], c0)
+		binary.LittleEndian.PutUint16(block[2:], c1)
+		binary.LittleEndian.PutUint32(block[4:], 0xE4E4E4E4)
+		return block
+	}
+	channelBlock := func(a, b byte) []byte {
+		var bits uint64
+		for i := 15; i >= 0; i-- {
+			bits = bits<<3 | uint64(i%8)
+		}
+		block := []byte{a, b}
+		for k := 0; k < 6; k++ {
+			block = append(block, byte(bits>>(8*uint(k))))
+		}
+		return block
+	}
+	// the alpha of pixel i is i%4
+	explicitAlpha := make([]byte, 8)
+	for i := range explicitAlpha {
+		explicitAlpha[i] = byte(2*i%4) | byte((2*i+1)%4)<<4
+	}
+
+	red, blue := uint16(0xF800), uint16(0x001F)
+	tests := []struct {
+		name   string
+		format DXGIFormat
+		block  []byte
+		// expected are the first pixels, later ones repeat
+		expected [][4]byte
+	}{
+		{"BC1 four colors", BC1_UNORM, colorBlock(red, blue),
+			[][4]byte{{255, 0, 0, 255}, {0, 0, 255, 255}, {170, 0, 85, 255}, {85, 0, 170, 255}}},
+		{"BC1 three colors", BC1_UNORM, colorBlock(blue, red),
+			[][4]byte{{0, 0, 255, 255}, {255, 0, 0, 255}, {127, 0, 127, 255}, {0, 0, 0, 0}}},
+		{"BC2 ignores color order", BC2_UNORM, append(explicitAlpha, colorBlock(blue, red)...),
+			[][4]byte{{0, 0, 255, 0x00}, {255, 0, 0, 0x11}, {85, 0, 170, 0x22}, {170, 0, 85, 0x33}}},
+		{"BC3 eight alphas", BC3_UNORM, append(channelBlock(255, 0), colorBlock(red, red)...),
+			[][4]byte{{255, 0, 0, 255}, {255, 0, 0, 0}, {255, 0, 0, 219}, {255, 0, 0, 182}, {255, 0, 0, 146}, {255, 0, 0, 109}, {255, 0, 0, 73}, {255, 0, 0, 36}}},
+		{"BC4 six values", BC4_UNORM, channelBlock(0, 255),
+			[][4]byte{{0, 0, 0, 255}, {255, 0, 0, 255}, {51, 0, 0, 255}, {102, 0, 0, 255}, {153, 0, 0, 255}, {204, 0, 0, 255}, {0, 0, 0, 255}, {255, 0, 0, 255}}},
+		{"BC4 signed", BC4_SNORM, channelBlock(0x7F, 0x81),
+			[][4]byte{{255, 0, 0, 255}, {0, 0, 0, 255}, {219, 0, 0, 255}, {182, 0, 0, 255}, {146, 0, 0, 255}, {109, 0, 0, 255}, {73, 0, 0, 255}, {36, 0, 0, 255}}},
+		{"BC5", BC5_UNORM, append(channelBlock(255, 0), channelBlock(0, 255)...),
+			[][4]byte{{255, 0, 0, 255}, {0, 255, 0, 255}, {219, 51, 0, 255}, {182, 102, 0, 255}, {146, 153, 0, 255}, {109, 204, 0, 255}, {73, 0, 0, 255}, {36, 255, 0, 255}}},
+	}
+
+	for _, test := range tests {
+		img := &Image{
+			DXGIFormat: test.format,
+			Width:      4, Height: 4, Depth: 1, ArraySize: 1,
+			Levels: []Level{{Width: 4, Height: 4, Depth: 1, Data: test.block}},
+		}
+		m, err := img.Decompress(0, 0)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		for i := 0; i < 16; i++ {
+			var got [4]byte
+			copy(got[:], m.Pix[m.PixOffset(i%4, i/4):])
+			if expected := test.expected[i%len(test.expected)]; got != expected {
+				t.Errorf("%v: pixel %d is %v, expected %v", test.name, i, got, expected)
+			}
+		}
+	}
+}
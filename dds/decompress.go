@@ -0,0 +1,295 @@
+package dds
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+func init() {
+	image.RegisterFormat("dds", "DDS ", DecodeImage, DecodeConfig)
+}
+
+// DecodeImage decodes the first level of a DDS file into an image,
+// the format can be any of the formats supported by Decompress.
+func DecodeImage(r io.Reader) (image.Image, error) {
+	img, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	return img.Decompress(0, 0)
+}
+
+// DecodeConfig returns the size of a DDS file without
+// reading the image data.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	img, _, err := decodeHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{
+		ColorModel: color.NRGBAModel,
+		Width:      img.Width,
+		Height:     img.Height,
+	}, nil
+}
+
+// Decompress converts a layer of a mipmap level into an image, see
+// Surface for the layer numbering. Slices of volume textures are
+// placed below each other.
+//
+// BC1, BC2, BC3, BC4, BC5 and R8G8B8A8 formats are supported. BC4 and
+// BC5 are decoded into the red and green channels, signed values
+// are mapped from [-1, 1] to [0, 255].
+func (img *Image) Decompress(level, layer int) (*image.NRGBA, error) {
+	if level < 0 || level >= len(img.Levels) || layer < 0 || layer >= img.Layers() {
+		return nil, fmt.Errorf("Level %d layer %d does not exist", level, layer)
+	}
+
+	size := img.Levels[level]
+	width, height := size.Width, size.Height*size.Depth
+	data := img.Surface(level, layer)
+	rgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	var decodeBlock func(block []byte, dst *[16][4]byte)
+	switch img.DXGIFormat {
+	case R8G8B8A8_UNORM, R8G8B8A8_UNORM_SRGB:
+		for y := 0; y < height; y++ {
+			copy(rgba.Pix[y*rgba.Stride:], data[y*width*4:(y+1)*width*4])
+		}
+		return rgba, nil
+	case BC1_UNORM, BC1_UNORM_SRGB:
+		decodeBlock = decodeBC1
+	case BC2_UNORM, BC2_UNORM_SRGB:
+		decodeBlock = decodeBC2
+	case BC3_UNORM, BC3_UNORM_SRGB:
+		decodeBlock = decodeBC3
+	case BC4_UNORM:
+		decodeBlock = decodeBC4
+	case BC4_SNORM:
+		decodeBlock = decodeBC4Signed
+	case BC5_UNORM:
+		decodeBlock = decodeBC5
+	case BC5_SNORM:
+		decodeBlock = decodeBC5Signed
+	default:
+		return nil, fmt.Errorf("Decompressing %v is not supported", img.DXGIFormat)
+	}
+
+	// volume slices are decoded separately, because
+	// the blocks do not cross the slice boundaries
+	blockSize := img.DXGIFormat.BlockSize()
+	sliceSize := img.DXGIFormat.LevelSize(size.Width, size.Height)
+
+	var pixels [16][4]byte
+	for slice := 0; slice < size.Depth; slice++ {
+		blocks := data[slice*sliceSize : (slice+1)*sliceSize]
+		top := slice * size.Height
+
+		for by := 0; by < (size.Height+3)/4; by++ {
+			for bx := 0; bx < (size.Width+3)/4; bx++ {
+				decodeBlock(blocks[:blockSize], &pixels)
+				blocks = blocks[blockSize:]
+
+				for i, pixel := range pixels {
+					x, y := bx*4+i%4, by*4+i/4
+					if x >= size.Width || y >= size.Height {
+						continue
+					}
+					copy(rgba.Pix[rgba.PixOffset(x, top+y):], pixel[:])
+				}
+			}
+		}
+	}
+
+	return rgba, nil
+}
+
+// decodeBC1 decodes a BC1 block, with 1-bit alpha
+func decodeBC1(block []byte, dst *[16][4]byte) {
+	decodeColors(block, dst, true)
+}
+
+// decodeBC2 decodes a BC2 block, with explicit 4-bit alpha
+func decodeBC2(block []byte, dst *[16][4]byte) {
+	decodeColors(block[8:], dst, false)
+	alpha := binary.LittleEndian.Uint64(block)
+	for i := range dst {
+		dst[i][3] = byte(alpha>>(4*uint(i))&0xF) * 0x11
+	}
+}
+
+// decodeBC3 decodes a BC3 block, with interpolated alpha
+func decodeBC3(block []byte, dst *[16][4]byte) {
+	decodeColors(block[8:], dst, false)
+	var alpha [16]byte
+	decodeChannel(block, &alpha)
+	for i := range dst {
+		dst[i][3] = alpha[i]
+	}
+}
+
+// decodeBC4 decodes a BC4 block into the red channel
+func decodeBC4(block []byte, dst *[16][4]byte) {
+	var red [16]byte
+	decodeChannel(block, &red)
+	for i := range dst {
+		dst[i] = [4]byte{red[i], 0, 0, 0xFF}
+	}
+}
+
+// decodeBC4Signed decodes a signed BC4 block into the red channel
+func decodeBC4Signed(block []byte, dst *[16][4]byte) {
+	var red [16]byte
+	decodeSignedChannel(block, &red)
+	for i := range dst {
+		dst[i] = [4]byte{red[i], 0, 0, 0xFF}
+	}
+}
+
+// decodeBC5 decodes a BC5 block into the red and green channels
+func decodeBC5(block []byte, dst *[16][4]byte) {
+	var red, green [16]byte
+	decodeChannel(block[:8], &red)
+	decodeChannel(block[8:], &green)
+	for i := range dst {
+		dst[i] = [4]byte{red[i], green[i], 0, 0xFF}
+	}
+}
+
+// decodeBC5Signed decodes a signed BC5 block into the red and green channels
+func decodeBC5Signed(block []byte, dst *[16][4]byte) {
+	var red, green [16]byte
+	decodeSignedChannel(block[:8], &red)
+	decodeSignedChannel(block[8:], &green)
+	for i := range dst {
+		dst[i] = [4]byte{red[i], green[i], 0, 0xFF}
+	}
+}
+
+// decodeColors decodes the color part of BC1, BC2 and BC3 blocks,
+// when punchthrough is false the four color mode is always used
+func decodeColors(block []byte, dst *[16][4]byte, punchthrough bool) {
+	c0 := binary.LittleEndian.Uint16(block[0:])
+	c1 := binary.LittleEndian.Uint16(block[2:])
+	indices := binary.LittleEndian.Uint32(block[4:])
+
+	palette := colorPalette(c0, c1, !punchthrough || c0 > c1)
+	for i := range dst {
+		dst[i] = palette[indices>>(2*uint(i))&3]
+	}
+}
+
+// colorPalette returns the colors of a BC1 style block, in the
+// three color mode the last entry is transparent black
+func colorPalette(c0, c1 uint16, fourColors bool) [4][4]byte {
+	var palette [4][4]byte
+	palette[0] = unpack565(c0)
+	palette[1] = unpack565(c1)
+	if fourColors {
+		for k := 0; k < 3; k++ {
+			a, b := int(palette[0][k]), int(palette[1][k])
+			palette[2][k] = byte((2*a + b + 1) / 3)
+			palette[3][k] = byte((a + 2*b + 1) / 3)
+		}
+		palette[2][3] = 0xFF
+		palette[3][3] = 0xFF
+	} else {
+		for k := 0; k < 3; k++ {
+			palette[2][k] = byte((int(palette[0][k]) + int(palette[1][k])) / 2)
+		}
+		palette[2][3] = 0xFF
+		palette[3] = [4]byte{0, 0, 0, 0}
+	}
+	return palette
+}
+
+// unpack565 converts a R5G6B5 color to 8-bit RGBA
+func unpack565(c uint16) [4]byte {
+	return [4]byte{
+		expand(uint32(c>>11&0x1F), 5),
+		expand(uint32(c>>5&0x3F), 6),
+		expand(uint32(c&0x1F), 5),
+		0xFF,
+	}
+}
+
+// decodeChannel decodes a BC4 style block with 8-bit endpoints
+// and 3-bit indices
+func decodeChannel(block []byte, dst *[16]byte) {
+	palette := channelPalette(int(block[0]), int(block[1]))
+
+	bits := channelIndices(block)
+	for i := range dst {
+		dst[i] = byte(palette[bits>>(3*uint(i))&7])
+	}
+}
+
+// channelPalette returns the values of an unsigned BC4 style block
+func channelPalette(a, b int) [8]int {
+	var palette [8]int
+	palette[0], palette[1] = a, b
+	if a > b {
+		for i := 1; i < 7; i++ {
+			palette[i+1] = ((7-i)*a + i*b + 3) / 7
+		}
+	} else {
+		for i := 1; i < 5; i++ {
+			palette[i+1] = ((5-i)*a + i*b + 2) / 5
+		}
+		palette[6], palette[7] = 0, 255
+	}
+	return palette
+}
+
+// decodeSignedChannel decodes a signed BC4 style block,
+// mapping values from [-127, 127] to [0, 255]
+func decodeSignedChannel(block []byte, dst *[16]byte) {
+	a, b := signed(block[0]), signed(block[1])
+
+	var palette [8]int
+	palette[0], palette[1] = a, b
+	if a > b {
+		for i := 1; i < 7; i++ {
+			palette[i+1] = roundDiv((7-i)*a+i*b, 7)
+		}
+	} else {
+		for i := 1; i < 5; i++ {
+			palette[i+1] = roundDiv((5-i)*a+i*b, 5)
+		}
+		palette[6], palette[7] = -127, 127
+	}
+
+	bits := channelIndices(block)
+	for i := range dst {
+		v := palette[bits>>(3*uint(i))&7]
+		dst[i] = byte(((v+127)*255 + 127) / 254)
+	}
+}
+
+// channelIndices returns the 48 bits of 3-bit indices of a BC4 style block
+func channelIndices(block []byte) uint64 {
+	var bits uint64
+	for k := 7; k >= 2; k-- {
+		bits = bits<<8 | uint64(block[k])
+	}
+	return bits
+}
+
+// signed converts a SNORM byte, -128 is treated as -127
+func signed(v byte) int {
+	if int8(v) == -128 {
+		return -127
+	}
+	return int(int8(v))
+}
+
+// roundDiv divides rounding to nearest, away from zero
+func roundDiv(a, b int) int {
+	if a < 0 {
+		return -((-a + b/2) / b)
+	}
+	return (a + b/2) / b
+}
@@ -0,0 +1,119 @@
+package dds
+
+import "fmt"
+
+// DXGIFormat is a DXGI_FORMAT value used by the DX10 header.
+type DXGIFormat uint32
+
+const (
+	UNKNOWN DXGIFormat = 0
+
+	R8G8B8A8_UNORM      DXGIFormat = 28
+	R8G8B8A8_UNORM_SRGB DXGIFormat = 29
+
+	BC1_UNORM      DXGIFormat = 71
+	BC1_UNORM_SRGB DXGIFormat = 72
+	BC2_UNORM      DXGIFormat = 74
+	BC2_UNORM_SRGB DXGIFormat = 75
+	BC3_UNORM      DXGIFormat = 77
+	BC3_UNORM_SRGB DXGIFormat = 78
+	BC4_UNORM      DXGIFormat = 80
+	BC4_SNORM      DXGIFormat = 81
+	BC5_UNORM      DXGIFormat = 83
+	BC5_SNORM      DXGIFormat = 84
+	BC6H_UF16      DXGIFormat = 95
+	BC6H_SF16      DXGIFormat = 96
+	BC7_UNORM      DXGIFormat = 98
+	BC7_UNORM_SRGB DXGIFormat = 99
+)
+
+var dxgiNames = map[DXGIFormat]string{
+	R8G8B8A8_UNORM:      "R8G8B8A8_UNORM",
+	R8G8B8A8_UNORM_SRGB: "R8G8B8A8_UNORM_SRGB",
+
+	BC1_UNORM:      "BC1_UNORM",
+	BC1_UNORM_SRGB: "BC1_UNORM_SRGB",
+	BC2_UNORM:      "BC2_UNORM",
+	BC2_UNORM_SRGB: "BC2_UNORM_SRGB",
+	BC3_UNORM:      "BC3_UNORM",
+	BC3_UNORM_SRGB: "BC3_UNORM_SRGB",
+	BC4_UNORM:      "BC4_UNORM",
+	BC4_SNORM:      "BC4_SNORM",
+	BC5_UNORM:      "BC5_UNORM",
+	BC5_SNORM:      "BC5_SNORM",
+	BC6H_UF16:      "BC6H_UF16",
+	BC6H_SF16:      "BC6H_SF16",
+	BC7_UNORM:      "BC7_UNORM",
+	BC7_UNORM_SRGB: "BC7_UNORM_SRGB",
+}
+
+func (format DXGIFormat) String() string {
+	if name, ok := dxgiNames[format]; ok {
+		return name
+	}
+	return fmt.Sprintf("DXGIFormat(%d)", uint32(format))
+}
+
+// Compressed returns whether format uses 4x4 blocks.
+func (format DXGIFormat) Compressed() bool {
+	return format.BlockSize() > 0
+}
+
+// BlockSize returns the size of a 4x4 block in bytes,
+// 0 for unknown and uncompressed formats.
+func (format DXGIFormat) BlockSize() int {
+	switch format {
+	case BC1_UNORM, BC1_UNORM_SRGB, BC4_UNORM, BC4_SNORM:
+		return 8
+	case BC2_UNORM, BC2_UNORM_SRGB, BC3_UNORM, BC3_UNORM_SRGB,
+		BC5_UNORM, BC5_SNORM, BC6H_UF16, BC6H_SF16, BC7_UNORM, BC7_UNORM_SRGB:
+		return 16
+	}
+	return 0
+}
+
+// PixelSize returns the size of a pixel in bytes,
+// 0 for unknown and compressed formats.
+func (format DXGIFormat) PixelSize() int {
+	switch format {
+	case R8G8B8A8_UNORM, R8G8B8A8_UNORM_SRGB:
+		return 4
+	}
+	return 0
+}
+
+// LevelSize returns the size of a width x height image in bytes,
+// 0 for unknown formats.
+func (format DXGIFormat) LevelSize(width, height int) int {
+	if format.Compressed() {
+		return ((width + 3) / 4) * ((height + 3) / 4) * format.BlockSize()
+	}
+	return width * height * format.PixelSize()
+}
+
+// FourCC codes
+const (
+	DXT1 = Format(0x31545844)
+	DXT3 = Format(0x33545844)
+	DXT5 = Format(0x35545844)
+	ATI1 = Format(0x31495441)
+	ATI2 = Format(0x32495441)
+	BC4U = Format(0x55344342)
+	BC4S = Format(0x53344342)
+	BC5U = Format(0x55354342)
+	BC5S = Format(0x53354342)
+	DX10 = Format(0x30315844)
+)
+
+// fourCCFormats maps legacy FourCC codes to DXGI formats
+var fourCCFormats = map[Format]DXGIFormat{
+	DXT1: BC1_UNORM,
+	DXT3: BC2_UNORM,
+	DXT5: BC3_UNORM,
+	ATI1: BC4_UNORM,
+	BC4U: BC4_UNORM,
+	BC4S: BC4_SNORM,
+	ATI2: BC5_UNORM,
+	BC5U: BC5_UNORM,
+	BC5S: BC5_SNORM,
+}
@@ -0,0 +1,191 @@
+package dds
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+func LoadFile(filename string) (uint32, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	return Load(bufio.NewReader(file))
+}
+
+// Load decodes a DDS file and uploads it as a texture.
+func Load(r io.Reader) (uint32, error) {
+	img, err := Decode(r)
+	if err != nil {
+		return 0, err
+	}
+	return Upload(img)
+}
+
+// sRGB variants of S3TC formats from EXT_texture_sRGB,
+// which are missing from the core profile bindings.
+const (
+	CompressedSRGBS3TCDXT1      = 0x8C4C
+	CompressedSRGBAlphaS3TCDXT1 = 0x8C4D
+	CompressedSRGBAlphaS3TCDXT3 = 0x8C4E
+	CompressedSRGBAlphaS3TCDXT5 = 0x8C4F
+)
+
+// InternalFormat returns the OpenGL internal format for format.
+func InternalFormat(format DXGIFormat) (uint32, error) {
+	switch format {
+	case R8G8B8A8_UNORM:
+		return gl.RGBA8, nil
+	case R8G8B8A8_UNORM_SRGB:
+		return gl.SRGB8_ALPHA8, nil
+	case BC1_UNORM:
+		return gl.COMPRESSED_RGBA_S3TC_DXT1_EXT, nil
+	case BC1_UNORM_SRGB:
+		return CompressedSRGBAlphaS3TCDXT1, nil
+	case BC2_UNORM:
+		return gl.COMPRESSED_RGBA_S3TC_DXT3_EXT, nil
+	case BC2_UNORM_SRGB:
+		return CompressedSRGBAlphaS3TCDXT3, nil
+	case BC3_UNORM:
+		return gl.COMPRESSED_RGBA_S3TC_DXT5_EXT, nil
+	case BC3_UNORM_SRGB:
+		return CompressedSRGBAlphaS3TCDXT5, nil
+	case BC4_UNORM:
+		return gl.COMPRESSED_RED_RGTC1, nil
+	case BC4_SNORM:
+		return gl.COMPRESSED_SIGNED_RED_RGTC1, nil
+	case BC5_UNORM:
+		return gl.COMPRESSED_RG_RGTC2, nil
+	case BC5_SNORM:
+		return gl.COMPRESSED_SIGNED_RG_RGTC2, nil
+	case BC6H_UF16:
+		return gl.COMPRESSED_RGB_BPTC_UNSIGNED_FLOAT_ARB, nil
+	case BC6H_SF16:
+		return gl.COMPRESSED_RGB_BPTC_SIGNED_FLOAT_ARB, nil
+	case BC7_UNORM:
+		return gl.COMPRESSED_RGBA_BPTC_UNORM_ARB, nil
+	case BC7_UNORM_SRGB:
+		return gl.COMPRESSED_SRGB_ALPHA_BPTC_UNORM_ARB, nil
+	}
+	return 0, fmt.Errorf("Unimplemented format %v", format)
+}
+
+// Target returns the texture target for img: TEXTURE_2D, TEXTURE_3D,
+// TEXTURE_2D_ARRAY, TEXTURE_CUBE_MAP or TEXTURE_CUBE_MAP_ARRAY.
+func (img *Image) Target() uint32 {
+	switch {
+	case img.Cubemap && img.ArraySize > 1:
+		return gl.TEXTURE_CUBE_MAP_ARRAY
+	case img.Cubemap:
+		return gl.TEXTURE_CUBE_MAP
+	case img.ArraySize > 1:
+		return gl.TEXTURE_2D_ARRAY
+	case img.Depth > 1:
+		return gl.TEXTURE_3D
+	}
+	return gl.TEXTURE_2D
+}
+
+// Upload uploads img as a new texture, use img.Target to bind it.
+//
+// The format is InternalFormat(img.DXGIFormat) unless
+// img.GLInternalFormat is set.
+func Upload(img *Image) (uint32, error) {
+	if len(img.Levels) == 0 {
+		return 0, errors.New("No image data")
+	}
+
+	format, err := img.transfer()
+	if err != nil {
+		return 0, err
+	}
+
+	target := img.Target()
+
+	var textureID uint32
+	gl.GenTextures(1, &textureID)
+
+	gl.BindTexture(target, textureID)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexParameteri(target, gl.TEXTURE_MAX_LEVEL, int32(len(img.Levels)-1))
+
+	for level, data := range img.Levels {
+		switch target {
+		case gl.TEXTURE_2D:
+			uploadImage2D(img, gl.TEXTURE_2D, level, format, data.Data)
+		case gl.TEXTURE_CUBE_MAP:
+			for face := 0; face < 6; face++ {
+				uploadImage2D(img, gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(face), level, format, img.Surface(level, face))
+			}
+		case gl.TEXTURE_3D:
+			uploadImage3D(img, target, level, format, data.Depth, data.Data)
+		default:
+			uploadImage3D(img, target, level, format, img.Layers(), data.Data)
+		}
+	}
+
+	return textureID, nil
+}
+
+// transfer describes how the data is passed to OpenGL
+type transfer struct {
+	internalFormat uint32
+	format         uint32
+	dataType       uint32
+	compressed     bool
+}
+
+func (img *Image) transfer() (transfer, error) {
+	if img.GLInternalFormat != 0 {
+		return transfer{
+			internalFormat: img.GLInternalFormat,
+			format:         img.GLFormat,
+			dataType:       img.GLType,
+			compressed:     img.GLFormat == 0,
+		}, nil
+	}
+
+	internalFormat, err := InternalFormat(img.DXGIFormat)
+	if err != nil {
+		return transfer{}, err
+	}
+	return transfer{
+		internalFormat: internalFormat,
+		format:         gl.RGBA,
+		dataType:       gl.UNSIGNED_BYTE,
+		compressed:     img.DXGIFormat.Compressed(),
+	}, nil
+}
+
+func uploadImage2D(img *Image, target uint32, level int, format transfer, data []byte) {
+	size := img.Levels[level]
+	if format.compressed {
+		gl.CompressedTexImage2D(target, int32(level), format.internalFormat,
+			int32(size.Width), int32(size.Height), 0,
+			int32(len(data)), gl.Ptr(data))
+	} else {
+		gl.TexImage2D(target, int32(level), int32(format.internalFormat),
+			int32(size.Width), int32(size.Height), 0,
+			format.format, format.dataType, gl.Ptr(data))
+	}
+}
+
+func uploadImage3D(img *Image, target uint32, level int, format transfer, depth int, data []byte) {
+	size := img.Levels[level]
+	if format.compressed {
+		gl.CompressedTexImage3D(target, int32(level), format.internalFormat,
+			int32(size.Width), int32(size.Height), int32(depth), 0,
+			int32(len(data)), gl.Ptr(data))
+	} else {
+		gl.TexImage3D(target, int32(level), int32(format.internalFormat),
+			int32(size.Width), int32(size.Height), int32(depth), 0,
+			format.format, format.dataType, gl.Ptr(data))
+	}
+}
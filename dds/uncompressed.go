@@ -0,0 +1,101 @@
+package dds
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// maskFormat is an uncompressed pixel format described by channel bit masks,
+// such as A8R8G8B8, X8R8G8B8, R5G6B5, A1R5G5B5, L8 or A8L8
+type maskFormat struct {
+	bitCount int
+	masks    [4]uint32 // red, green, blue, alpha
+}
+
+// parseMaskFormat parses the pixel format from pixel format flags
+// and the RGBBitCount, RBitMask, GBitMask, BBitMask, ABitMask fields
+func parseMaskFormat(flags uint32, fields []byte) (*maskFormat, error) {
+	enc := binary.LittleEndian
+
+	format := &maskFormat{}
+	format.bitCount = int(enc.Uint32(fields[0:]))
+	switch format.bitCount {
+	case 8, 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("Unimplemented bit count %d", format.bitCount)
+	}
+
+	r := enc.Uint32(fields[4:])
+	g := enc.Uint32(fields[8:])
+	b := enc.Uint32(fields[12:])
+	a := enc.Uint32(fields[16:])
+	if flags&(PixelAlphaPixels|PixelAlpha) == 0 {
+		a = 0
+	}
+
+	switch {
+	case flags&PixelRGB != 0:
+		if r|g|b == 0 {
+			return nil, fmt.Errorf("Missing color masks")
+		}
+		format.masks = [4]uint32{r, g, b, a}
+	case flags&PixelLuminance != 0:
+		if r == 0 {
+			return nil, fmt.Errorf("Missing luminance mask")
+		}
+		format.masks = [4]uint32{r, r, r, a}
+	case flags&PixelAlpha != 0:
+		if a == 0 {
+			return nil, fmt.Errorf("Missing alpha mask")
+		}
+		format.masks = [4]uint32{0, 0, 0, a}
+	default:
+		return nil, fmt.Errorf("Unimplemented pixel format flags 0x%x", flags)
+	}
+
+	return format, nil
+}
+
+func (format *maskFormat) rowSize(width int) int { return (width*format.bitCount + 7) / 8 }
+
+func (format *maskFormat) levelSize(width, height int) int {
+	return format.rowSize(width) * height
+}
+
+// toRGBA converts data to tightly packed 8-bit RGBA
+func (format *maskFormat) toRGBA(data []byte, width, height int) []byte {
+	bytesPerPixel := format.bitCount / 8
+
+	var shifts, widths [4]uint
+	for k, mask := range format.masks {
+		shifts[k] = uint(bits.TrailingZeros32(mask))
+		widths[k] = uint(bits.OnesCount32(mask))
+	}
+
+	rgba := make([]byte, width*height*4)
+	for i := 0; i < width*height; i++ {
+		var pixel uint32
+		for k := 0; k < bytesPerPixel; k++ {
+			pixel |= uint32(data[i*bytesPerPixel+k]) << (8 * uint(k))
+		}
+
+		for k, mask := range format.masks {
+			var value byte
+			switch {
+			case mask != 0:
+				value = expand((pixel&mask)>>shifts[k], widths[k])
+			case k == 3:
+				value = 0xFF
+			}
+			rgba[i*4+k] = value
+		}
+	}
+	return rgba
+}
+
+// expand scales a value with n bits to 8 bits
+func expand(value uint32, n uint) byte {
+	max := uint32(1)<<n - 1
+	return byte((value*255 + max/2) / max)
+}
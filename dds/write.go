@@ -0,0 +1,177 @@
+package dds
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Caps flags
+const (
+	CapsComplex = 0x8
+	CapsTexture = 0x1000
+	CapsMipMap  = 0x400000
+)
+
+// legacyFourCC maps DXGI formats to FourCC codes
+// that can be written without the DX10 header
+var legacyFourCC = map[DXGIFormat]Format{
+	BC1_UNORM: DXT1,
+	BC2_UNORM: DXT3,
+	BC3_UNORM: DXT5,
+	BC4_UNORM: BC4U,
+	BC4_SNORM: BC4S,
+	BC5_UNORM: BC5U,
+	BC5_SNORM: BC5S,
+}
+
+// WriteFile writes img to filename, see Write.
+func WriteFile(filename string, img *Image) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := Write(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Write writes img as a DDS file.
+//
+// Formats that have a FourCC code are written with the legacy
+// header, others and texture arrays with the DX10 header.
+// Format and Flags of img are ignored.
+func Write(w io.Writer, img *Image) error {
+	if len(img.Levels) == 0 {
+		return errors.New("No image data")
+	}
+	if img.DXGIFormat.LevelSize(1, 1) == 0 {
+		return fmt.Errorf("Unimplemented format %v", img.DXGIFormat)
+	}
+
+	layers := img.Layers()
+	for level, data := range img.Levels {
+		expected := img.DXGIFormat.LevelSize(data.Width, data.Height) * data.Depth * layers
+		if len(data.Data) != expected {
+			return fmt.Errorf("Mipmap level %d has %d bytes, expected %d", level, len(data.Data), expected)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := writeHeader(bw, img); err != nil {
+		return err
+	}
+
+	for layer := 0; layer < layers; layer++ {
+		for level := range img.Levels {
+			if _, err := bw.Write(img.Surface(level, layer)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+func writeHeader(w io.Writer, img *Image) error {
+	enc := binary.LittleEndian
+
+	var buf [4 + headerSize]byte
+	copy(buf[:], "DDS ")
+	hdr := buf[4:]
+
+	flags := uint32(FlagCaps | FlagHeight | FlagWidth | FlagPixelFormat)
+	caps := uint32(CapsTexture)
+	caps2 := uint32(0)
+
+	if len(img.Levels) > 1 {
+		flags |= FlagMipMapCount
+		caps |= CapsMipMap | CapsComplex
+	}
+	if img.Cubemap {
+		caps |= CapsComplex
+		caps2 |= Caps2Cubemap | Caps2CubemapAllFaces
+	}
+
+	depth := img.Depth
+	if depth < 1 {
+		depth = 1
+	}
+	if depth > 1 {
+		flags |= FlagDepth
+		caps |= CapsComplex
+		caps2 |= Caps2Volume
+	}
+
+	linearSize := img.DXGIFormat.LevelSize(img.Width, img.Height)
+	if img.DXGIFormat.Compressed() {
+		flags |= FlagLinearSize
+	} else {
+		flags |= FlagPitch
+		linearSize = img.Width * img.DXGIFormat.PixelSize()
+	}
+
+	enc.PutUint32(hdr[0:], headerSize)
+	enc.PutUint32(hdr[4:], flags)
+	enc.PutUint32(hdr[8:], uint32(img.Height))
+	enc.PutUint32(hdr[12:], uint32(img.Width))
+	enc.PutUint32(hdr[16:], uint32(linearSize))
+	enc.PutUint32(hdr[20:], uint32(depth))
+	enc.PutUint32(hdr[24:], uint32(len(img.Levels)))
+	enc.PutUint32(hdr[72:], pixelFormatSize)
+	enc.PutUint32(hdr[104:], caps)
+	enc.PutUint32(hdr[108:], caps2)
+
+	fourCC, legacy := legacyFourCC[img.DXGIFormat]
+	switch {
+	case img.ArraySize > 1:
+		legacy = false
+	case img.DXGIFormat == R8G8B8A8_UNORM:
+		enc.PutUint32(hdr[76:], PixelRGB|PixelAlphaPixels)
+		enc.PutUint32(hdr[84:], 32)
+		enc.PutUint32(hdr[88:], 0x000000FF)
+		enc.PutUint32(hdr[92:], 0x0000FF00)
+		enc.PutUint32(hdr[96:], 0x00FF0000)
+		enc.PutUint32(hdr[100:], 0xFF000000)
+		_, err := w.Write(buf[:])
+		return err
+	}
+
+	if legacy {
+		enc.PutUint32(hdr[76:], PixelFourCC)
+		enc.PutUint32(hdr[80:], uint32(fourCC))
+		_, err := w.Write(buf[:])
+		return err
+	}
+
+	enc.PutUint32(hdr[76:], PixelFourCC)
+	enc.PutUint32(hdr[80:], uint32(DX10))
+
+	var ext [dx10HeaderSize]byte
+	enc.PutUint32(ext[0:], uint32(img.DXGIFormat))
+	if depth > 1 {
+		enc.PutUint32(ext[4:], DimensionTexture3D)
+	} else {
+		enc.PutUint32(ext[4:], DimensionTexture2D)
+	}
+	if img.Cubemap {
+		enc.PutUint32(ext[8:], MiscTextureCube)
+	}
+	arraySize := img.ArraySize
+	if arraySize < 1 {
+		arraySize = 1
+	}
+	enc.PutUint32(ext[12:], uint32(arraySize))
+
+	if _, err := w.Write(buf[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(ext[:])
+	return err
+}
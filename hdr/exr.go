@@ -0,0 +1,376 @@
+package hdr
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const exrMagic = "\x76\x2f\x31\x01"
+
+// version flags
+const (
+	exrTiled     = 0x200
+	exrDeep      = 0x800
+	exrMultipart = 0x1000
+)
+
+// channel pixel types
+const (
+	exrUint  = 0
+	exrHalf  = 1
+	exrFloat = 2
+)
+
+// compression methods
+const (
+	exrNoCompression   = 0
+	exrZIPSCompression = 2
+	exrZIPCompression  = 3
+)
+
+// exrChannel is a channel of the image, the channels are
+// stored in the file sorted by name
+type exrChannel struct {
+	name      string
+	pixelType int32
+	// dst is the index of the RGB component, -1 if it is ignored
+	dst int
+}
+
+func (channel exrChannel) size() int {
+	if channel.pixelType == exrHalf {
+		return 2
+	}
+	return 4
+}
+
+// exrHeader is the parsed header of a single part scanline file
+type exrHeader struct {
+	channels    []exrChannel
+	compression byte
+	// dataWindow is xMin, yMin, xMax, yMax, inclusive
+	dataWindow [4]int32
+}
+
+func (header *exrHeader) width() int {
+	return int(int64(header.dataWindow[2])-int64(header.dataWindow[0])) + 1
+}
+
+func (header *exrHeader) height() int {
+	return int(int64(header.dataWindow[3])-int64(header.dataWindow[1])) + 1
+}
+
+// linesPerChunk returns the number of scanlines in a chunk
+func (header *exrHeader) linesPerChunk() int {
+	if header.compression == exrZIPCompression {
+		return 16
+	}
+	return 1
+}
+
+// DecodeEXR decodes a single part scanline OpenEXR file that is
+// uncompressed or ZIP compressed. R, G and B channels or a Y
+// luminance channel are read, other channels are ignored.
+func DecodeEXR(r io.Reader) (*Image, error) {
+	// chunks are located by the offset table, so
+	// the whole file is read
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	header, offsets, err := decodeEXRHeader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	img := NewImage(header.width(), header.height())
+	lines := header.linesPerChunk()
+	for i, offset := range offsets {
+		if offset < 8 || offset > uint64(len(data))-8 {
+			return nil, fmt.Errorf("Invalid offset %d of chunk %d", offset, i)
+		}
+		chunk := data[offset:]
+
+		y := int(int64(int32(binary.LittleEndian.Uint32(chunk[0:]))) - int64(header.dataWindow[1]))
+		size := int(int32(binary.LittleEndian.Uint32(chunk[4:])))
+		if y < 0 || y >= img.Height || y%lines != 0 {
+			return nil, fmt.Errorf("Invalid scanline %d in chunk %d", y, i)
+		}
+		if size < 0 || size > len(chunk)-8 {
+			return nil, fmt.Errorf("Truncated chunk %d", i)
+		}
+
+		count := lines
+		if y+count > img.Height {
+			count = img.Height - y
+		}
+		pixels, err := header.decompress(chunk[8:8+size], count)
+		if err != nil {
+			return nil, fmt.Errorf("Chunk %d: %v", i, err)
+		}
+		header.readPixels(img, y, count, pixels)
+	}
+
+	// a single luminance channel is copied to green and blue
+	if header.luminance() {
+		for i := 0; i < len(img.Pix); i += 3 {
+			img.Pix[i+1], img.Pix[i+2] = img.Pix[i], img.Pix[i]
+		}
+	}
+
+	return img, nil
+}
+
+// luminance reports whether the image has a Y channel instead of RGB
+func (header *exrHeader) luminance() bool {
+	for _, channel := range header.channels {
+		if channel.name == "Y" && channel.dst == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// lineSize returns the size of a scanline of all channels
+func (header *exrHeader) lineSize() int {
+	size := 0
+	for _, channel := range header.channels {
+		size += channel.size() * header.width()
+	}
+	return size
+}
+
+// decompress returns the uncompressed pixels of a chunk
+func (header *exrHeader) decompress(data []byte, lines int) ([]byte, error) {
+	expected := header.lineSize() * lines
+
+	// data that does not compress is stored as is
+	if header.compression == exrNoCompression || len(data) == expected {
+		if len(data) != expected {
+			return nil, fmt.Errorf("Chunk has %d bytes, expected %d", len(data), expected)
+		}
+		return data, nil
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	predicted := make([]byte, expected)
+	if _, err := io.ReadFull(zr, predicted); err != nil {
+		return nil, fmt.Errorf("Decompressing failed: %v", err)
+	}
+
+	// undo the delta predictor
+	for i := 1; i < len(predicted); i++ {
+		predicted[i] = predicted[i-1] + predicted[i] - 128
+	}
+
+	// the first half contains the even bytes, the second half the odd ones
+	pixels := make([]byte, expected)
+	half := (expected + 1) / 2
+	for i := range pixels {
+		if i%2 == 0 {
+			pixels[i] = predicted[i/2]
+		} else {
+			pixels[i] = predicted[half+i/2]
+		}
+	}
+	return pixels, nil
+}
+
+// readPixels converts lines starting at y into img
+func (header *exrHeader) readPixels(img *Image, y, lines int, pixels []byte) {
+	width := img.Width
+	for line := 0; line < lines; line++ {
+		dst := img.Pix[3*(y+line)*width:]
+		for _, channel := range header.channels {
+			size := channel.size()
+			values := pixels[:size*width]
+			pixels = pixels[size*width:]
+			if channel.dst < 0 {
+				continue
+			}
+
+			for x := 0; x < width; x++ {
+				var v float32
+				switch channel.pixelType {
+				case exrHalf:
+					v = halfToFloat(binary.LittleEndian.Uint16(values[2*x:]))
+				case exrFloat:
+					v = math.Float32frombits(binary.LittleEndian.Uint32(values[4*x:]))
+				case exrUint:
+					v = float32(binary.LittleEndian.Uint32(values[4*x:]))
+				}
+				dst[3*x+channel.dst] = v
+			}
+		}
+	}
+}
+
+// decodeEXRHeader parses the header and the chunk offset table
+func decodeEXRHeader(r io.Reader) (*exrHeader, []uint64, error) {
+	br := bufio.NewReader(r)
+
+	var start [8]byte
+	if _, err := io.ReadFull(br, start[:]); err != nil {
+		return nil, nil, err
+	}
+	if string(start[:4]) != exrMagic {
+		return nil, nil, errors.New("Not OpenEXR file")
+	}
+
+	version := binary.LittleEndian.Uint32(start[4:])
+	if version&0xFF != 2 {
+		return nil, nil, fmt.Errorf("Unimplemented version %d", version&0xFF)
+	}
+	if version&(exrTiled|exrDeep|exrMultipart) != 0 {
+		return nil, nil, fmt.Errorf("Unimplemented tiled, deep or multipart file, flags 0x%x", version&^0xFF)
+	}
+
+	header := &exrHeader{}
+	var hasChannels, hasWindow bool
+	for {
+		name, err := readString(br)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Truncated header: %v", err)
+		}
+		if name == "" {
+			break
+		}
+		typeName, err := readString(br)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Truncated header: %v", err)
+		}
+
+		var sizeBuf [4]byte
+		if _, err := io.ReadFull(br, sizeBuf[:]); err != nil {
+			return nil, nil, fmt.Errorf("Truncated header: %v", err)
+		}
+		size := int(int32(binary.LittleEndian.Uint32(sizeBuf[:])))
+		if size < 0 || size > 1<<20 {
+			return nil, nil, fmt.Errorf("Invalid size %d of attribute %v", size, name)
+		}
+		value := make([]byte, size)
+		if _, err := io.ReadFull(br, value); err != nil {
+			return nil, nil, fmt.Errorf("Truncated attribute %v: %v", name, err)
+		}
+
+		switch {
+		case name == "channels" && typeName == "chlist":
+			if header.channels, err = parseChannels(value); err != nil {
+				return nil, nil, err
+			}
+			hasChannels = true
+		case name == "compression" && typeName == "compression" && size == 1:
+			header.compression = value[0]
+		case name == "dataWindow" && typeName == "box2i" && size == 16:
+			for k := range header.dataWindow {
+				header.dataWindow[k] = int32(binary.LittleEndian.Uint32(value[4*k:]))
+			}
+			hasWindow = true
+		}
+	}
+
+	if !hasChannels || !hasWindow {
+		return nil, nil, errors.New("Missing channels or dataWindow attribute")
+	}
+	switch header.compression {
+	case exrNoCompression, exrZIPSCompression, exrZIPCompression:
+	default:
+		return nil, nil, fmt.Errorf("Unimplemented compression %d", header.compression)
+	}
+
+	width, height := int64(header.width()), int64(header.height())
+	if width <= 0 || height <= 0 || width > maxSize || height > maxSize {
+		return nil, nil, fmt.Errorf("Invalid size %dx%d", width, height)
+	}
+
+	lines := header.linesPerChunk()
+	offsets := make([]uint64, (header.height()+lines-1)/lines)
+	if err := binary.Read(br, binary.LittleEndian, offsets); err != nil {
+		return nil, nil, fmt.Errorf("Truncated offset table: %v", err)
+	}
+
+	return header, offsets, nil
+}
+
+// parseChannels parses a chlist attribute
+func parseChannels(value []byte) ([]exrChannel, error) {
+	var channels []exrChannel
+	rgb, luminance := false, false
+	for {
+		end := bytes.IndexByte(value, 0)
+		if end < 0 {
+			return nil, errors.New("Truncated channel list")
+		}
+		if end == 0 {
+			break
+		}
+		name := string(value[:end])
+		value = value[end+1:]
+		if len(value) < 16 {
+			return nil, errors.New("Truncated channel list")
+		}
+
+		channel := exrChannel{
+			name:      name,
+			pixelType: int32(binary.LittleEndian.Uint32(value[0:])),
+			dst:       -1,
+		}
+		xSampling := int32(binary.LittleEndian.Uint32(value[8:]))
+		ySampling := int32(binary.LittleEndian.Uint32(value[12:]))
+		value = value[16:]
+
+		if channel.pixelType < exrUint || channel.pixelType > exrFloat {
+			return nil, fmt.Errorf("Invalid pixel type %d of channel %v", channel.pixelType, name)
+		}
+		if xSampling != 1 || ySampling != 1 {
+			return nil, fmt.Errorf("Unimplemented subsampling of channel %v", name)
+		}
+
+		switch name {
+		case "R", "Y":
+			channel.dst = 0
+		case "G":
+			channel.dst = 1
+		case "B":
+			channel.dst = 2
+		}
+		rgb = rgb || name == "R" || name == "G" || name == "B"
+		luminance = luminance || name == "Y"
+		channels = append(channels, channel)
+	}
+
+	if !rgb && !luminance {
+		return nil, errors.New("Missing R, G, B or Y channel")
+	}
+	// luminance is only used without color channels
+	if rgb {
+		for i := range channels {
+			if channels[i].name == "Y" {
+				channels[i].dst = -1
+			}
+		}
+	}
+	return channels, nil
+}
+
+// readString reads a null terminated string
+func readString(br *bufio.Reader) (string, error) {
+	s, err := br.ReadString(0)
+	if err != nil {
+		return "", err
+	}
+	if len(s) > 256 {
+		return "", errors.New("Name too long")
+	}
+	return s[:len(s)-1], nil
+}
@@ -0,0 +1,276 @@
+package hdr
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"math"
+	"strings"
+	"testing"
+)
+
+// testChannel is a channel of a test file
+type testChannel struct {
+	name      string
+	pixelType int32
+	// value returns the value at x, y
+	value func(x, y int) float32
+}
+
+// testEXR describes a single part scanline OpenEXR file
+type testEXR struct {
+	version     uint32
+	channels    []testChannel
+	compression byte
+	// dataWindow is xMin, yMin, xMax, yMax, inclusive
+	dataWindow [4]int32
+}
+
+// half values of the test pixels, the values are exact
+var halfs = map[float32]uint16{0: 0x0000, 0.5: 0x3800, 1: 0x3C00, 2: 0x4000, 3: 0x4200, -1: 0xBC00, -2: 0xC000}
+
+func (e testEXR) width() int  { return int(e.dataWindow[2]-e.dataWindow[0]) + 1 }
+func (e testEXR) height() int { return int(e.dataWindow[3]-e.dataWindow[1]) + 1 }
+
+// chunk returns the uncompressed pixels of lines starting at y
+func (e testEXR) chunk(y, lines int) []byte {
+	var data []byte
+	for line := y; line < y+lines && line < e.height(); line++ {
+		for _, channel := range e.channels {
+			for x := 0; x < e.width(); x++ {
+				v := channel.value(x, line)
+				switch channel.pixelType {
+				case exrHalf:
+					data = binary.LittleEndian.AppendUint16(data, halfs[v])
+				case exrFloat:
+					data = binary.LittleEndian.AppendUint32(data, math.Float32bits(v))
+				case exrUint:
+					data = binary.LittleEndian.AppendUint32(data, uint32(v))
+				}
+			}
+		}
+	}
+	return data
+}
+
+// header returns the magic, version and attributes
+func (e testEXR) header() []byte {
+	enc := binary.LittleEndian
+	if e.version == 0 {
+		e.version = 2
+	}
+	data := enc.AppendUint32([]byte(exrMagic), e.version)
+
+	attribute := func(name, typeName string, value []byte) {
+		data = append(data, name+"\x00"+typeName+"\x00"...)
+		data = enc.AppendUint32(data, uint32(len(value)))
+		data = append(data, value...)
+	}
+
+	var channels []byte
+	for _, channel := range e.channels {
+		channels = append(channels, channel.name+"\x00"...)
+		channels = enc.AppendUint32(channels, uint32(channel.pixelType))
+		channels = append(channels, 0, 0, 0, 0)
+		channels = enc.AppendUint32(channels, 1)
+		channels = enc.AppendUint32(channels, 1)
+	}
+	channels = append(channels, 0)
+
+	var window []byte
+	for _, v := range e.dataWindow {
+		window = enc.AppendUint32(window, uint32(v))
+	}
+
+	attribute("channels", "chlist", channels)
+	attribute("compression", "compression", []byte{e.compression})
+	attribute("dataWindow", "box2i", window)
+	attribute("lineOrder", "lineOrder", []byte{0})
+	return append(data, 0)
+}
+
+// file returns the header, the offset table and the chunks,
+// ZIP and ZIPS chunks are compressed
+func (e testEXR) file() []byte {
+	lines := 1
+	if e.compression == exrZIPCompression {
+		lines = 16
+	}
+
+	var chunks [][]byte
+	for y := 0; y < e.height(); y += lines {
+		data := e.chunk(y, lines)
+		if e.compression != exrNoCompression {
+			data = zipChunk(data)
+		}
+		chunk := binary.LittleEndian.AppendUint32(nil, uint32(int(e.dataWindow[1])+y))
+		chunk = binary.LittleEndian.AppendUint32(chunk, uint32(len(data)))
+		chunks = append(chunks, append(chunk, data...))
+	}
+
+	data := e.header()
+	offset := len(data) + 8*len(chunks)
+	for _, chunk := range chunks {
+		data = binary.LittleEndian.AppendUint64(data, uint64(offset))
+		offset += len(chunk)
+	}
+	for _, chunk := range chunks {
+		data = append(data, chunk...)
+	}
+	return data
+}
+
+// zipChunk splits the even and odd bytes, applies the
+// delta predictor and compresses the result
+func zipChunk(data []byte) []byte {
+	split := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i += 2 {
+		split = append(split, data[i])
+	}
+	for i := 1; i < len(data); i += 2 {
+		split = append(split, data[i])
+	}
+	predicted := make([]byte, len(split))
+	for i := range split {
+		predicted[i] = split[i]
+		if i > 0 {
+			predicted[i] = split[i] - split[i-1] + 128
+		}
+	}
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(predicted)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// rgbEXR returns a 5x19 image with channels sorted by name, half
+// and float channels and an ignored alpha, the window does not
+// start at the origin
+func rgbEXR(compression byte) testEXR {
+	return testEXR{
+		channels: []testChannel{
+			{"A", exrHalf, func(x, y int) float32 { return 1 }},
+			{"B", exrFloat, func(x, y int) float32 { return float32(x) * 0.5 }},
+			{"G", exrHalf, func(x, y int) float32 { return float32(y % 4) }},
+			{"R", exrFloat, func(x, y int) float32 { return float32(x+y) + 100 }},
+		},
+		compression: compression,
+		dataWindow:  [4]int32{10, -20, 14, -2},
+	}
+}
+
+func TestDecodeEXR(t *testing.T) {
+	rgb := func(x, y int) [3]float32 { return [3]float32{float32(x+y) + 100, float32(y % 4), float32(x) * 0.5} }
+
+	luminance := testEXR{
+		channels: []testChannel{
+			{"Y", exrHalf, func(x, y int) float32 { return float32(x) - 2 }},
+		},
+		dataWindow: [4]int32{0, 0, 4, 1},
+	}
+	integer := testEXR{
+		channels: []testChannel{
+			{"B", exrUint, func(x, y int) float32 { return 3 }},
+			{"G", exrUint, func(x, y int) float32 { return 2 }},
+			{"R", exrUint, func(x, y int) float32 { return 1 }},
+			{"Y", exrHalf, func(x, y int) float32 { return 0.5 }},
+		},
+		dataWindow: [4]int32{0, 0, 0, 0},
+	}
+
+	tests := []struct {
+		name   string
+		file   []byte
+		width  int
+		height int
+		rgb    func(x, y int) [3]float32
+	}{
+		{"uncompressed", rgbEXR(exrNoCompression).file(), 5, 19, rgb},
+		{"ZIPS", rgbEXR(exrZIPSCompression).file(), 5, 19, rgb},
+		{"ZIP", rgbEXR(exrZIPCompression).file(), 5, 19, rgb},
+		{"luminance", luminance.file(), 5, 2, func(x, y int) [3]float32 {
+			v := float32(x) - 2
+			return [3]float32{v, v, v}
+		}},
+		{"luminance with color", integer.file(), 1, 1, func(x, y int) [3]float32 { return [3]float32{1, 2, 3} }},
+	}
+
+	for _, test := range tests {
+		config, name, err := image.DecodeConfig(bytes.NewReader(test.file))
+		if err != nil || name != "exr" || config.Width != test.width || config.Height != test.height {
+			t.Errorf("%v: got config %v %dx%d, %v", test.name, name, config.Width, config.Height, err)
+		}
+
+		img, err := Decode(bytes.NewReader(test.file))
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if img.Width != test.width || img.Height != test.height {
+			t.Errorf("%v: got %dx%d, expected %dx%d", test.name, img.Width, img.Height, test.width, test.height)
+			continue
+		}
+		for y := 0; y < img.Height; y++ {
+			for x := 0; x < img.Width; x++ {
+				if got, expected := img.RGB(x, y), test.rgb(x, y); got != expected {
+					t.Errorf("%v: %d,%d got %v, expected %v", test.name, x, y, got, expected)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeEXRErrors(t *testing.T) {
+	base := rgbEXR(exrNoCompression)
+	with := func(change func(e *testEXR)) []byte {
+		e := base
+		e.channels = append([]testChannel(nil), base.channels...)
+		change(&e)
+		return e.file()
+	}
+
+	// the offset table starts after the header
+	file := base.file()
+	tableStart := len(base.header())
+	firstChunk := binary.LittleEndian.Uint64(file[tableStart:])
+	patched := func(file []byte, at int, value []byte) []byte {
+		data := append([]byte(nil), file...)
+		copy(data[at:], value)
+		return data
+	}
+
+	zips := rgbEXR(exrZIPSCompression)
+	zipsFile := zips.file()
+	zipsChunk := int(binary.LittleEndian.Uint64(zipsFile[len(zips.header()):]))
+
+	tests := []struct {
+		name string
+		file []byte
+		err  string
+	}{
+		{"version", with(func(e *testEXR) { e.version = 1 }), "Unimplemented version 1"},
+		{"tiled", with(func(e *testEXR) { e.version = 2 | exrTiled }), "Unimplemented tiled, deep or multipart file, flags 0x200"},
+		{"truncated header", file[:tableStart-20], "Truncated"},
+		{"compression", with(func(e *testEXR) { e.compression = 4 }), "Unimplemented compression 4"},
+		{"pixel type", with(func(e *testEXR) { e.channels[0].pixelType = 3 }), "Invalid pixel type 3 of channel A"},
+		{"missing color", with(func(e *testEXR) { e.channels = e.channels[:1] }), "Missing R, G, B or Y channel"},
+		{"size", with(func(e *testEXR) { e.dataWindow[2] = e.dataWindow[0] - 1 }), "Invalid size 0x19"},
+		{"too large", with(func(e *testEXR) { e.dataWindow = [4]int32{math.MinInt32, 0, math.MaxInt32, 0} }), "Invalid size 4294967296x1"},
+		{"truncated offset table", file[:tableStart+12], "Truncated offset table"},
+		{"offset", patched(file, tableStart, binary.LittleEndian.AppendUint64(nil, uint64(len(file)))), "Invalid offset"},
+		{"scanline", patched(file, int(firstChunk), binary.LittleEndian.AppendUint32(nil, 100)), "Invalid scanline 120 in chunk 0"},
+		{"chunk size", patched(file, int(firstChunk)+4, binary.LittleEndian.AppendUint32(nil, 1<<20)), "Truncated chunk 0"},
+		{"uncompressed size", patched(file, int(firstChunk)+4, binary.LittleEndian.AppendUint32(nil, 10)), "Chunk 0: Chunk has 10 bytes, expected 60"},
+		{"corrupt ZIP", patched(zipsFile, zipsChunk+8, []byte{0xFF, 0xFF}), "Chunk 0: "},
+	}
+
+	for _, test := range tests {
+		_, err := Decode(bytes.NewReader(test.file))
+		if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("%v: got error %v, expected %q", test.name, err, test.err)
+		}
+	}
+}
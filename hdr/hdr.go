@@ -0,0 +1,152 @@
+// Package hdr decodes high dynamic range images from Radiance RGBE
+// (.hdr) and OpenEXR files and encodes Radiance files.
+package hdr
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"os"
+)
+
+func init() {
+	image.RegisterFormat("hdr", "#?", decodeImage, decodeConfig)
+	image.RegisterFormat("exr", exrMagic, decodeImage, decodeConfig)
+}
+
+// Image is a linear floating point RGB image.
+//
+// Pix contains the red, green and blue values of each pixel, rows
+// follow each other starting with the top row. It implements
+// image.Image by clamping the values to [0, 1], without tone mapping.
+type Image struct {
+	Width  int
+	Height int
+	Pix    []float32
+}
+
+// NewImage returns a black image of the given size.
+func NewImage(width, height int) *Image {
+	return &Image{
+		Width:  width,
+		Height: height,
+		Pix:    make([]float32, 3*width*height),
+	}
+}
+
+// RGB returns the color of the pixel at x, y.
+func (img *Image) RGB(x, y int) [3]float32 {
+	i := 3 * (y*img.Width + x)
+	return [3]float32{img.Pix[i], img.Pix[i+1], img.Pix[i+2]}
+}
+
+// SetRGB sets the color of the pixel at x, y.
+func (img *Image) SetRGB(x, y int, c [3]float32) {
+	i := 3 * (y*img.Width + x)
+	copy(img.Pix[i:i+3], c[:])
+}
+
+func (img *Image) ColorModel() color.Model { return color.NRGBA64Model }
+func (img *Image) Bounds() image.Rectangle { return image.Rect(0, 0, img.Width, img.Height) }
+
+func (img *Image) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(img.Bounds())) {
+		return color.NRGBA64{}
+	}
+	c := img.RGB(x, y)
+	return color.NRGBA64{clamp16(c[0]), clamp16(c[1]), clamp16(c[2]), 0xFFFF}
+}
+
+func clamp16(v float32) uint16 {
+	if !(v > 0) {
+		return 0
+	}
+	if v >= 1 {
+		return 0xFFFF
+	}
+	return uint16(v*0xFFFF + 0.5)
+}
+
+func DecodeFile(filename string) (*Image, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Decode(file)
+}
+
+// Decode decodes a Radiance or OpenEXR file, the format is
+// detected from the content.
+func Decode(r io.Reader) (*Image, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte("#?")):
+		return DecodeRadiance(br)
+	case string(magic) == exrMagic:
+		return DecodeEXR(br)
+	}
+	return nil, errors.New("Not Radiance or OpenEXR file")
+}
+
+func decodeImage(r io.Reader) (image.Image, error) {
+	return Decode(r)
+}
+
+func decodeConfig(r io.Reader) (image.Config, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	config := image.Config{ColorModel: color.NRGBA64Model}
+	if string(magic) == exrMagic {
+		header, _, err := decodeEXRHeader(br)
+		if err != nil {
+			return image.Config{}, err
+		}
+		config.Width, config.Height = header.width(), header.height()
+	} else {
+		header, err := decodeRadianceHeader(br)
+		if err != nil {
+			return image.Config{}, err
+		}
+		config.Width, config.Height = header.width, header.height
+	}
+	return config, nil
+}
+
+// maxSize is the maximum supported width or height
+const maxSize = 1 << 16
+
+// halfToFloat converts an IEEE 754 half precision value
+func halfToFloat(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exponent := uint32(h >> 10 & 0x1F)
+	mantissa := uint32(h & 0x3FF)
+
+	switch exponent {
+	case 0:
+		// zero or subnormal
+		v := float32(mantissa) / (1 << 24)
+		if sign != 0 {
+			v = -v
+		}
+		return v
+	case 0x1F:
+		// infinity or NaN
+		return math.Float32frombits(sign | 0xFF<<23 | mantissa<<13)
+	}
+	return math.Float32frombits(sign | (exponent+127-15)<<23 | mantissa<<13)
+}
@@ -0,0 +1,327 @@
+package hdr
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// radianceHeader is the parsed header of a Radiance file
+type radianceHeader struct {
+	width  int
+	height int
+	// flipY is set when the rows are stored bottom to top
+	flipY bool
+}
+
+// DecodeRadiance decodes a Radiance RGBE file in the standard
+// -Y height +X width or the +Y height +X width orientation.
+func DecodeRadiance(r io.Reader) (*Image, error) {
+	br := bufio.NewReader(r)
+	header, err := decodeRadianceHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	img := NewImage(header.width, header.height)
+	scanline := make([]byte, 4*header.width)
+	for y := 0; y < header.height; y++ {
+		if err := readScanline(br, scanline); err != nil {
+			return nil, fmt.Errorf("Scanline %d: %v", y, err)
+		}
+
+		row := y
+		if header.flipY {
+			row = header.height - 1 - y
+		}
+		dst := img.Pix[3*row*header.width:]
+		for x := 0; x < header.width; x++ {
+			rgb := rgbeToFloat(scanline[4*x : 4*x+4])
+			copy(dst[3*x:], rgb[:])
+		}
+	}
+	return img, nil
+}
+
+func decodeRadianceHeader(br *bufio.Reader) (radianceHeader, error) {
+	var header radianceHeader
+
+	line, err := readLine(br)
+	if err != nil {
+		return header, err
+	}
+	if line != "#?RADIANCE" && line != "#?RGBE" {
+		return header, errors.New("Not Radiance file")
+	}
+
+	// the header ends with an empty line
+	for {
+		line, err := readLine(br)
+		if err != nil {
+			return header, fmt.Errorf("Truncated header: %v", err)
+		}
+		if line == "" {
+			break
+		}
+		if format := strings.TrimPrefix(line, "FORMAT="); format != line && format != "32-bit_rle_rgbe" {
+			return header, fmt.Errorf("Unimplemented format %v", format)
+		}
+	}
+
+	line, err = readLine(br)
+	if err != nil {
+		return header, fmt.Errorf("Missing resolution: %v", err)
+	}
+
+	var yAxis, xAxis string
+	if _, err := fmt.Sscanf(line, "%s %d %s %d", &yAxis, &header.height, &xAxis, &header.width); err != nil {
+		return header, fmt.Errorf("Invalid resolution %q", line)
+	}
+	switch {
+	case yAxis == "-Y" && xAxis == "+X":
+	case yAxis == "+Y" && xAxis == "+X":
+		header.flipY = true
+	default:
+		return header, fmt.Errorf("Unimplemented orientation %q", line)
+	}
+	if header.width <= 0 || header.height <= 0 || header.width > maxSize || header.height > maxSize {
+		return header, fmt.Errorf("Invalid size %dx%d", header.width, header.height)
+	}
+
+	return header, nil
+}
+
+// readLine reads a line without the line ending,
+// the header lines are short
+func readLine(br *bufio.Reader) (string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) > 1<<12 {
+		return "", errors.New("Header line too long")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readScanline reads a scanline of RGBE pixels, it can be flat,
+// run length encoded per channel or use the old run length encoding
+func readScanline(br *bufio.Reader, dst []byte) error {
+	width := len(dst) / 4
+
+	start, err := br.Peek(4)
+	if err != nil {
+		return err
+	}
+	if width < 8 || width >= 0x8000 || start[0] != 2 || start[1] != 2 || start[2]&0x80 != 0 {
+		return readFlatScanline(br, dst)
+	}
+	if int(start[2])<<8|int(start[3]) != width {
+		return fmt.Errorf("Scanline width %d, expected %d", int(start[2])<<8|int(start[3]), width)
+	}
+	br.Discard(4)
+
+	// the channels are stored one after another
+	for channel := 0; channel < 4; channel++ {
+		for x := 0; x < width; {
+			count, err := br.ReadByte()
+			if err != nil {
+				return err
+			}
+
+			if count > 128 {
+				n := int(count) - 128
+				value, err := br.ReadByte()
+				if err != nil {
+					return err
+				}
+				if x+n > width {
+					return errors.New("Run exceeds scanline")
+				}
+				for ; n > 0; n-- {
+					dst[4*x+channel] = value
+					x++
+				}
+			} else {
+				n := int(count)
+				if n == 0 || x+n > width {
+					return errors.New("Invalid run length")
+				}
+				for ; n > 0; n-- {
+					value, err := br.ReadByte()
+					if err != nil {
+						return err
+					}
+					dst[4*x+channel] = value
+					x++
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// readFlatScanline reads uncompressed pixels, where pixels
+// 1, 1, 1, n repeat the previous pixel
+func readFlatScanline(br *bufio.Reader, dst []byte) error {
+	width := len(dst) / 4
+	shift := uint(0)
+	for x := 0; x < width; {
+		var pixel [4]byte
+		if _, err := io.ReadFull(br, pixel[:]); err != nil {
+			return err
+		}
+
+		if pixel[0] == 1 && pixel[1] == 1 && pixel[2] == 1 {
+			if x == 0 {
+				return errors.New("Repeat at the start of scanline")
+			}
+			n := int(pixel[3]) << shift
+			if x+n > width {
+				return errors.New("Run exceeds scanline")
+			}
+			for ; n > 0; n-- {
+				copy(dst[4*x:4*x+4], dst[4*x-4:4*x])
+				x++
+			}
+			shift += 8
+			continue
+		}
+
+		copy(dst[4*x:4*x+4], pixel[:])
+		x++
+		shift = 0
+	}
+	return nil
+}
+
+// rgbeToFloat converts a pixel with a shared exponent
+func rgbeToFloat(rgbe []byte) [3]float32 {
+	if rgbe[3] == 0 {
+		return [3]float32{}
+	}
+	f := float32(math.Ldexp(1, int(rgbe[3])-(128+8)))
+	return [3]float32{float32(rgbe[0]) * f, float32(rgbe[1]) * f, float32(rgbe[2]) * f}
+}
+
+// floatToRGBE converts a color to a pixel with a shared exponent,
+// negative values are clamped to zero
+func floatToRGBE(c [3]float32) [4]byte {
+	v := c[0]
+	if c[1] > v {
+		v = c[1]
+	}
+	if c[2] > v {
+		v = c[2]
+	}
+	if !(v > 1e-32) {
+		return [4]byte{}
+	}
+
+	mantissa, exponent := math.Frexp(float64(v))
+	if exponent > 127 {
+		return [4]byte{255, 255, 255, 255}
+	}
+	scale := float32(mantissa * 256 / float64(v))
+
+	var rgbe [4]byte
+	for k, value := range c {
+		if value > 0 {
+			rgbe[k] = byte(value * scale)
+		}
+	}
+	rgbe[3] = byte(exponent + 128)
+	return rgbe
+}
+
+// WriteFile writes img to filename in Radiance format.
+func WriteFile(filename string, img *Image) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := WriteRadiance(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WriteRadiance writes img in Radiance RGBE format with run
+// length encoded scanlines.
+func WriteRadiance(w io.Writer, img *Image) error {
+	if img.Width <= 0 || img.Height <= 0 || len(img.Pix) != 3*img.Width*img.Height {
+		return fmt.Errorf("Invalid image %dx%d with %d values", img.Width, img.Height, len(img.Pix))
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y %d +X %d\n", img.Height, img.Width)
+
+	encoded := img.Width >= 8 && img.Width < 0x8000
+	scanline := make([]byte, 4*img.Width)
+	channel := make([]byte, img.Width)
+	for y := 0; y < img.Height; y++ {
+		for x := 0; x < img.Width; x++ {
+			rgbe := floatToRGBE(img.RGB(x, y))
+			copy(scanline[4*x:], rgbe[:])
+		}
+
+		if !encoded {
+			bw.Write(scanline)
+			continue
+		}
+
+		bw.Write([]byte{2, 2, byte(img.Width >> 8), byte(img.Width)})
+		for k := 0; k < 4; k++ {
+			for x := range channel {
+				channel[x] = scanline[4*x+k]
+			}
+			writeRuns(bw, channel)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// writeRuns run length encodes a channel, runs shorter
+// than minRun are written as literals
+func writeRuns(bw *bufio.Writer, data []byte) {
+	const minRun = 4
+
+	for len(data) > 0 {
+		// find the start of the next run
+		literal := 0
+		for literal < len(data) && literal < 128 {
+			if runLength(data[literal:]) >= minRun {
+				break
+			}
+			literal++
+		}
+
+		if literal > 0 {
+			bw.WriteByte(byte(literal))
+			bw.Write(data[:literal])
+			data = data[literal:]
+			continue
+		}
+
+		run := runLength(data)
+		bw.WriteByte(byte(128 + run))
+		bw.WriteByte(data[0])
+		data = data[run:]
+	}
+}
+
+// runLength returns the number of repeated first values, at most 127
+func runLength(data []byte) int {
+	n := 1
+	for n < len(data) && n < 127 && data[n] == data[0] {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,165 @@
+package hdr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+	"strings"
+	"testing"
+)
+
+// radianceFile returns a Radiance file with the scanlines
+func radianceFile(resolution string, scanlines ...[]byte) []byte {
+	data := []byte("#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n" + resolution + "\n")
+	for _, scanline := range scanlines {
+		data = append(data, scanline...)
+	}
+	return data
+}
+
+// checkRGBE checks that got matches expected within the precision
+// of the shared exponent, relative to the largest component
+func checkRGBE(t *testing.T, name string, got, expected *Image) {
+	t.Helper()
+	if got.Width != expected.Width || got.Height != expected.Height {
+		t.Fatalf("%v: got %dx%d, expected %dx%d", name, got.Width, got.Height, expected.Width, expected.Height)
+	}
+	for i := 0; i < len(expected.Pix); i += 3 {
+		c := expected.Pix[i : i+3]
+		largest := math.Max(float64(c[0]), math.Max(float64(c[1]), float64(c[2])))
+		for k := 0; k < 3; k++ {
+			if diff := math.Abs(float64(got.Pix[i+k] - c[k])); diff > largest/128 {
+				t.Errorf("%v: pixel %d got %v, expected %v", name, i/3, got.Pix[i:i+3], c)
+				return
+			}
+		}
+	}
+}
+
+func TestRadianceRoundTrip(t *testing.T) {
+	// narrow images are written flat, others with runs and literals
+	// that are longer than the maximum of 127 and 128
+	for _, width := range []int{5, 8, 300} {
+		img := NewImage(width, 3)
+		for i := range img.Pix {
+			img.Pix[i] = float32(i%7) * 3.7 / float32(1+i%5)
+		}
+		for i := 0; i < 3*width; i++ {
+			img.Pix[i] = 2.5
+		}
+		img.SetRGB(0, 1, [3]float32{1e-40, -1, 0})
+		img.SetRGB(1, 1, [3]float32{1000, 0.001, 1})
+
+		var buf bytes.Buffer
+		if err := WriteRadiance(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+
+		config, name, err := image.DecodeConfig(bytes.NewReader(buf.Bytes()))
+		if err != nil || name != "hdr" || config.Width != width || config.Height != 3 {
+			t.Errorf("%d: got config %v %dx%d, %v", width, name, config.Width, config.Height, err)
+		}
+
+		got, err := Decode(&buf)
+		if err != nil {
+			t.Errorf("%d: %v", width, err)
+			continue
+		}
+
+		// negative values are clamped to zero
+		img.SetRGB(0, 1, [3]float32{})
+		checkRGBE(t, fmt.Sprintf("width %d", width), got, img)
+	}
+
+	if err := WriteRadiance(&bytes.Buffer{}, &Image{Width: 2, Height: 2}); err == nil {
+		t.Errorf("invalid image did not fail")
+	}
+}
+
+func TestDecodeRadiance(t *testing.T) {
+	pixel := []byte{128, 64, 32, 129}
+	rgb := [3]float32{1, 0.5, 0.25}
+
+	// new run length encoding stores the channels separately
+	encoded := []byte{2, 2, 0, 9}
+	encoded = append(encoded, 128+9, 128)
+	encoded = append(encoded, 2, 64, 64, 128+7, 64)
+	encoded = append(encoded, 9, 32, 32, 32, 32, 32, 32, 32, 32, 32)
+	encoded = append(encoded, 128+9, 129)
+
+	// old run length encoding repeats the previous pixel,
+	// consecutive repeats shift the count by 8 bits
+	var old []byte
+	old = append(old, pixel...)
+	old = append(old, 1, 1, 1, 2)
+	old = append(old, 1, 1, 1, 1)
+
+	tests := []struct {
+		name   string
+		file   []byte
+		width  int
+		height int
+	}{
+		{"flat", radianceFile("-Y 2 +X 2", pixel, pixel, pixel, pixel), 2, 2},
+		{"flipped", radianceFile("+Y 1 +X 3", pixel, pixel, pixel), 3, 1},
+		{"new run length encoding", radianceFile("-Y 2 +X 9", encoded, encoded), 9, 2},
+		{"old run length encoding", radianceFile("-Y 1 +X 259", old), 259, 1},
+	}
+
+	for _, test := range tests {
+		img, err := Decode(bytes.NewReader(test.file))
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		expected := NewImage(test.width, test.height)
+		for i := 0; i < len(expected.Pix); i += 3 {
+			copy(expected.Pix[i:], rgb[:])
+		}
+		checkRGBE(t, test.name, img, expected)
+	}
+
+	// +Y stores the rows bottom to top
+	flipped := radianceFile("+Y 2 +X 1", []byte{128, 0, 0, 129}, []byte{0, 128, 0, 129})
+	img, err := Decode(bytes.NewReader(flipped))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.RGB(0, 0); got != [3]float32{0, 1, 0} {
+		t.Errorf("flipped: got top %v, expected the last scanline", got)
+	}
+}
+
+func TestDecodeRadianceErrors(t *testing.T) {
+	pixel := []byte{128, 64, 32, 129}
+
+	tests := []struct {
+		name string
+		file []byte
+		err  string
+	}{
+		{"magic", []byte("#?PICTURE\n\n-Y 1 +X 1\n"), "Not Radiance file"},
+		{"format", []byte("#?RADIANCE\nFORMAT=32-bit_rle_xyze\n\n-Y 1 +X 1\n"), "Unimplemented format 32-bit_rle_xyze"},
+		{"truncated header", []byte("#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n"), "Truncated header"},
+		{"missing resolution", []byte("#?RADIANCE\n\n"), "Missing resolution"},
+		{"resolution", radianceFile("-Y two +X 1"), `Invalid resolution "-Y two +X 1"`},
+		{"orientation", radianceFile("-X 1 +Y 1"), `Unimplemented orientation "-X 1 +Y 1"`},
+		{"size", radianceFile("-Y 0 +X 1"), "Invalid size 1x0"},
+		{"too large", radianceFile("-Y 1 +X 100000"), "Invalid size 100000x1"},
+		{"truncated scanline", radianceFile("-Y 2 +X 1", pixel), "Scanline 1: EOF"},
+		{"scanline width", radianceFile("-Y 1 +X 9", []byte{2, 2, 0, 8}), "Scanline 0: Scanline width 8, expected 9"},
+		{"run exceeds scanline", radianceFile("-Y 1 +X 9", []byte{2, 2, 0, 9, 128 + 10, 0}), "Scanline 0: Run exceeds scanline"},
+		{"zero run length", radianceFile("-Y 1 +X 9", []byte{2, 2, 0, 9, 0}), "Scanline 0: Invalid run length"},
+		{"literal exceeds scanline", radianceFile("-Y 1 +X 9", []byte{2, 2, 0, 9, 10}), "Scanline 0: Invalid run length"},
+		{"repeat at start", radianceFile("-Y 1 +X 2", []byte{1, 1, 1, 1}), "Scanline 0: Repeat at the start of scanline"},
+		{"repeat exceeds scanline", radianceFile("-Y 1 +X 2", pixel, []byte{1, 1, 1, 2}), "Scanline 0: Run exceeds scanline"},
+	}
+
+	for _, test := range tests {
+		_, err := Decode(bytes.NewReader(test.file))
+		if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("%v: got error %v, expected %q", test.name, err, test.err)
+		}
+	}
+}
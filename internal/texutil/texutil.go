@@ -0,0 +1,39 @@
+// Package texutil contains helpers shared by the texture decoders.
+package texutil
+
+import (
+	"bytes"
+	"io"
+)
+
+// ReadData reads size bytes from r. The buffer grows as the data is
+// read to avoid large allocations for invalid headers; err is io.EOF
+// when fewer than size bytes could be read.
+func ReadData(r io.Reader, size int) ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := io.CopyN(&buf, r, int64(size))
+	return buf.Bytes(), err
+}
+
+// MaxMipMapCount returns the number of levels in a full mipmap chain.
+func MaxMipMapCount(width, height, depth int) int {
+	count := 1
+	for width > 1 || height > 1 || depth > 1 {
+		width, height, depth = NextLevel(width, height, depth)
+		count++
+	}
+	return count
+}
+
+// NextLevel returns the size of the next smaller mipmap level,
+// each dimension is halved and rounded down, but at least 1.
+func NextLevel(width, height, depth int) (int, int, int) {
+	return half(width), half(height), half(depth)
+}
+
+func half(v int) int {
+	if v <= 1 {
+		return 1
+	}
+	return v / 2
+}
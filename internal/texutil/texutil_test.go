@@ -0,0 +1,44 @@
+package texutil
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMipMapChain(t *testing.T) {
+	tests := []struct {
+		width, height, depth int
+		count                int
+	}{
+		{1, 1, 1, 1},
+		{2, 1, 1, 2},
+		{4, 4, 1, 3},
+		{7, 3, 1, 3},
+		{5, 8, 1, 4},
+		{4, 2, 16, 5},
+		{1 << 16, 1, 1, 17},
+	}
+	for _, test := range tests {
+		if got := MaxMipMapCount(test.width, test.height, test.depth); got != test.count {
+			t.Errorf("%dx%dx%d: got %d levels, expected %d", test.width, test.height, test.depth, got, test.count)
+		}
+	}
+
+	if w, h, d := NextLevel(7, 1, 0); w != 3 || h != 1 || d != 1 {
+		t.Errorf("got %dx%dx%d, expected 3x1x1", w, h, d)
+	}
+}
+
+func TestReadData(t *testing.T) {
+	data, err := ReadData(strings.NewReader("abcdef"), 4)
+	if err != nil || string(data) != "abcd" {
+		t.Errorf("got %q, %v", data, err)
+	}
+
+	data, err = ReadData(bytes.NewReader([]byte("ab")), 1<<30)
+	if err != io.EOF || string(data) != "ab" {
+		t.Errorf("truncated: got %q, %v", data, err)
+	}
+}
@@ -0,0 +1,105 @@
+package ktx
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+
+	"github.com/egonelbre/opengl-tutorial.org/dds"
+)
+
+// formatInfo describes a supported OpenGL internal format
+type formatInfo struct {
+	// dxgi is the equivalent DXGI format, if there is one
+	dxgi dds.DXGIFormat
+	// format and dataType are used for uploading,
+	// format is 0 for compressed formats
+	format   uint32
+	dataType uint32
+	// size is the size of a pixel, or of a 4x4 block
+	// for compressed formats
+	size int
+}
+
+func (info formatInfo) compressed() bool { return info.format == 0 }
+
+// levelSize returns the size of a 2D surface
+func (info formatInfo) levelSize(width, height int) int {
+	if info.compressed() {
+		return ((width + 3) / 4) * ((height + 3) / 4) * info.size
+	}
+	return width * height * info.size
+}
+
+// formats are indexed by the OpenGL internal format
+var formats = map[uint32]formatInfo{
+	gl.R8:           {dds.UNKNOWN, gl.RED, gl.UNSIGNED_BYTE, 1},
+	gl.RG8:          {dds.UNKNOWN, gl.RG, gl.UNSIGNED_BYTE, 2},
+	gl.RGB8:         {dds.UNKNOWN, gl.RGB, gl.UNSIGNED_BYTE, 3},
+	gl.SRGB8:        {dds.UNKNOWN, gl.RGB, gl.UNSIGNED_BYTE, 3},
+	gl.RGBA8:        {dds.R8G8B8A8_UNORM, gl.RGBA, gl.UNSIGNED_BYTE, 4},
+	gl.SRGB8_ALPHA8: {dds.R8G8B8A8_UNORM_SRGB, gl.RGBA, gl.UNSIGNED_BYTE, 4},
+	gl.RGB16F:       {dds.UNKNOWN, gl.RGB, gl.HALF_FLOAT, 6},
+	gl.RGBA16F:      {dds.UNKNOWN, gl.RGBA, gl.HALF_FLOAT, 8},
+	gl.RGB32F:       {dds.UNKNOWN, gl.RGB, gl.FLOAT, 12},
+	gl.RGBA32F:      {dds.UNKNOWN, gl.RGBA, gl.FLOAT, 16},
+
+	gl.COMPRESSED_RGB_S3TC_DXT1_EXT:  {dds.BC1_UNORM, 0, 0, 8},
+	gl.COMPRESSED_RGBA_S3TC_DXT1_EXT: {dds.BC1_UNORM, 0, 0, 8},
+	dds.CompressedSRGBS3TCDXT1:       {dds.BC1_UNORM_SRGB, 0, 0, 8},
+	dds.CompressedSRGBAlphaS3TCDXT1:  {dds.BC1_UNORM_SRGB, 0, 0, 8},
+	gl.COMPRESSED_RGBA_S3TC_DXT3_EXT: {dds.BC2_UNORM, 0, 0, 16},
+	dds.CompressedSRGBAlphaS3TCDXT3:  {dds.BC2_UNORM_SRGB, 0, 0, 16},
+	gl.COMPRESSED_RGBA_S3TC_DXT5_EXT: {dds.BC3_UNORM, 0, 0, 16},
+	dds.CompressedSRGBAlphaS3TCDXT5:  {dds.BC3_UNORM_SRGB, 0, 0, 16},
+
+	gl.COMPRESSED_RED_RGTC1:        {dds.BC4_UNORM, 0, 0, 8},
+	gl.COMPRESSED_SIGNED_RED_RGTC1: {dds.BC4_SNORM, 0, 0, 8},
+	gl.COMPRESSED_RG_RGTC2:         {dds.BC5_UNORM, 0, 0, 16},
+	gl.COMPRESSED_SIGNED_RG_RGTC2:  {dds.BC5_SNORM, 0, 0, 16},
+
+	gl.COMPRESSED_RGB_BPTC_UNSIGNED_FLOAT_ARB: {dds.BC6H_UF16, 0, 0, 16},
+	gl.COMPRESSED_RGB_BPTC_SIGNED_FLOAT_ARB:   {dds.BC6H_SF16, 0, 0, 16},
+	gl.COMPRESSED_RGBA_BPTC_UNORM_ARB:         {dds.BC7_UNORM, 0, 0, 16},
+	gl.COMPRESSED_SRGB_ALPHA_BPTC_UNORM_ARB:   {dds.BC7_UNORM_SRGB, 0, 0, 16},
+
+	gl.COMPRESSED_RGB8_ETC2:             {dds.UNKNOWN, 0, 0, 8},
+	gl.COMPRESSED_SRGB8_ETC2:            {dds.UNKNOWN, 0, 0, 8},
+	gl.COMPRESSED_RGBA8_ETC2_EAC:        {dds.UNKNOWN, 0, 0, 16},
+	gl.COMPRESSED_SRGB8_ALPHA8_ETC2_EAC: {dds.UNKNOWN, 0, 0, 16},
+}
+
+// vkFormats maps the Vulkan formats of KTX2 files to OpenGL internal formats
+var vkFormats = map[uint32]uint32{
+	9:   gl.R8,           // VK_FORMAT_R8_UNORM
+	16:  gl.RG8,          // VK_FORMAT_R8G8_UNORM
+	23:  gl.RGB8,         // VK_FORMAT_R8G8B8_UNORM
+	29:  gl.SRGB8,        // VK_FORMAT_R8G8B8_SRGB
+	37:  gl.RGBA8,        // VK_FORMAT_R8G8B8A8_UNORM
+	43:  gl.SRGB8_ALPHA8, // VK_FORMAT_R8G8B8A8_SRGB
+	90:  gl.RGB16F,       // VK_FORMAT_R16G16B16_SFLOAT
+	97:  gl.RGBA16F,      // VK_FORMAT_R16G16B16A16_SFLOAT
+	106: gl.RGB32F,       // VK_FORMAT_R32G32B32_SFLOAT
+	109: gl.RGBA32F,      // VK_FORMAT_R32G32B32A32_SFLOAT
+
+	131: gl.COMPRESSED_RGB_S3TC_DXT1_EXT,  // VK_FORMAT_BC1_RGB_UNORM_BLOCK
+	132: dds.CompressedSRGBS3TCDXT1,       // VK_FORMAT_BC1_RGB_SRGB_BLOCK
+	133: gl.COMPRESSED_RGBA_S3TC_DXT1_EXT, // VK_FORMAT_BC1_RGBA_UNORM_BLOCK
+	134: dds.CompressedSRGBAlphaS3TCDXT1,  // VK_FORMAT_BC1_RGBA_SRGB_BLOCK
+	135: gl.COMPRESSED_RGBA_S3TC_DXT3_EXT, // VK_FORMAT_BC2_UNORM_BLOCK
+	136: dds.CompressedSRGBAlphaS3TCDXT3,  // VK_FORMAT_BC2_SRGB_BLOCK
+	137: gl.COMPRESSED_RGBA_S3TC_DXT5_EXT, // VK_FORMAT_BC3_UNORM_BLOCK
+	138: dds.CompressedSRGBAlphaS3TCDXT5,  // VK_FORMAT_BC3_SRGB_BLOCK
+	139: gl.COMPRESSED_RED_RGTC1,          // VK_FORMAT_BC4_UNORM_BLOCK
+	140: gl.COMPRESSED_SIGNED_RED_RGTC1,   // VK_FORMAT_BC4_SNORM_BLOCK
+	141: gl.COMPRESSED_RG_RGTC2,           // VK_FORMAT_BC5_UNORM_BLOCK
+	142: gl.COMPRESSED_SIGNED_RG_RGTC2,    // VK_FORMAT_BC5_SNORM_BLOCK
+
+	143: gl.COMPRESSED_RGB_BPTC_UNSIGNED_FLOAT_ARB, // VK_FORMAT_BC6H_UFLOAT_BLOCK
+	144: gl.COMPRESSED_RGB_BPTC_SIGNED_FLOAT_ARB,   // VK_FORMAT_BC6H_SFLOAT_BLOCK
+	145: gl.COMPRESSED_RGBA_BPTC_UNORM_ARB,         // VK_FORMAT_BC7_UNORM_BLOCK
+	146: gl.COMPRESSED_SRGB_ALPHA_BPTC_UNORM_ARB,   // VK_FORMAT_BC7_SRGB_BLOCK
+
+	147: gl.COMPRESSED_RGB8_ETC2,             // VK_FORMAT_ETC2_R8G8B8_UNORM_BLOCK
+	148: gl.COMPRESSED_SRGB8_ETC2,            // VK_FORMAT_ETC2_R8G8B8_SRGB_BLOCK
+	151: gl.COMPRESSED_RGBA8_ETC2_EAC,        // VK_FORMAT_ETC2_R8G8B8A8_UNORM_BLOCK
+	152: gl.COMPRESSED_SRGB8_ALPHA8_ETC2_EAC, // VK_FORMAT_ETC2_R8G8B8A8_SRGB_BLOCK
+}
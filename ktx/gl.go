@@ -0,0 +1,28 @@
+package ktx
+
+import (
+	"io"
+	"os"
+
+	"github.com/egonelbre/opengl-tutorial.org/dds"
+)
+
+func LoadFile(filename string) (uint32, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	return Load(file)
+}
+
+// Load decodes a KTX file and uploads it as a texture with the
+// internal format from the file, use dds.Image.Target to bind it.
+func Load(r io.Reader) (uint32, error) {
+	img, err := Decode(r)
+	if err != nil {
+		return 0, err
+	}
+	return dds.Upload(img)
+}
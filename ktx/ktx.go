@@ -0,0 +1,289 @@
+// Package ktx decodes KTX 1.1 and KTX2 textures into dds.Image,
+// so they can be used the same way as DDS files.
+package ktx
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/egonelbre/opengl-tutorial.org/dds"
+	"github.com/egonelbre/opengl-tutorial.org/internal/texutil"
+)
+
+const (
+	identifier1 = "\xABKTX 11\xBB\r\n\x1A\n"
+	identifier2 = "\xABKTX 20\xBB\r\n\x1A\n"
+)
+
+const (
+	header1Size = 52
+	header2Size = 68
+
+	// endianness is the value of the endianness field
+	// of a little-endian KTX 1.1 file
+	endianness = 0x04030201
+)
+
+// maxSize is the maximum supported width, height, depth or array size
+const maxSize = 1 << 16
+
+// DecodeFile opens and decodes a KTX 1.1 or KTX2 file, see Decode.
+func DecodeFile(filename string) (*dds.Image, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Decode(file)
+}
+
+// Decode parses a KTX 1.1 or KTX2 file without uploading it.
+//
+// The image has the GL formats from the file set, and DXGIFormat
+// when the format has an equivalent, otherwise it is dds.UNKNOWN.
+// Layers are ordered as in dds.Image.Surface.
+func Decode(r io.Reader) (*dds.Image, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(identifier1))
+	if err != nil {
+		return nil, err
+	}
+
+	switch string(magic) {
+	case identifier1:
+		br.Discard(len(identifier1))
+		return decode1(br)
+	case identifier2:
+		br.Discard(len(identifier2))
+		return decode2(br)
+	}
+	return nil, errors.New("Not KTX file")
+}
+
+// newImage validates the dimensions and creates the image without data,
+// zero height, depth and array size mean that the dimension is not used
+func newImage(internalFormat uint32, width, height, depth, arraySize, faces, levels int) (*dds.Image, formatInfo, error) {
+	info, ok := formats[internalFormat]
+	if !ok {
+		return nil, info, fmt.Errorf("Unimplemented internal format 0x%x", internalFormat)
+	}
+
+	if height == 0 {
+		height = 1
+	}
+	if depth == 0 {
+		depth = 1
+	}
+	if arraySize == 0 {
+		arraySize = 1
+	}
+	if levels == 0 {
+		levels = 1
+	}
+
+	if width <= 0 || height <= 0 || width > maxSize || height > maxSize || depth > maxSize {
+		return nil, info, fmt.Errorf("Invalid size %dx%dx%d", width, height, depth)
+	}
+	if arraySize < 0 || arraySize > maxSize {
+		return nil, info, fmt.Errorf("Invalid array size %d", arraySize)
+	}
+	if depth > 1 && (arraySize > 1 || faces != 1) {
+		return nil, info, errors.New("Volume texture arrays and cubemaps are not supported")
+	}
+	switch faces {
+	case 1:
+	case 6:
+		if width != height {
+			return nil, info, fmt.Errorf("Cubemap faces are not square %dx%d", width, height)
+		}
+	default:
+		return nil, info, fmt.Errorf("Invalid number of faces %d", faces)
+	}
+	if max := texutil.MaxMipMapCount(width, height, depth); levels < 0 || levels > max {
+		return nil, info, fmt.Errorf("Invalid mipmap count %d for %dx%dx%d, expected at most %d",
+			levels, width, height, depth, max)
+	}
+
+	img := &dds.Image{
+		DXGIFormat:       info.dxgi,
+		Width:            width,
+		Height:           height,
+		Depth:            depth,
+		ArraySize:        arraySize,
+		Cubemap:          faces == 6,
+		GLInternalFormat: internalFormat,
+		GLFormat:         info.format,
+		GLType:           info.dataType,
+	}
+	for level := 0; level < levels; level++ {
+		img.Levels = append(img.Levels, dds.Level{
+			Width:  width,
+			Height: height,
+			Depth:  depth,
+		})
+		width, height, depth = texutil.NextLevel(width, height, depth)
+	}
+	return img, info, nil
+}
+
+// decode1 parses a KTX 1.1 file after the identifier
+func decode1(r io.Reader) (*dds.Image, error) {
+	var buf [header1Size]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return nil, fmt.Errorf("Truncated header: %v", err)
+	}
+
+	enc := binary.LittleEndian
+	field := func(i int) uint32 { return enc.Uint32(buf[4*i:]) }
+
+	switch field(0) {
+	case endianness:
+	case 0x01020304:
+		return nil, errors.New("Unimplemented big-endian file")
+	default:
+		return nil, fmt.Errorf("Invalid endianness 0x%x", field(0))
+	}
+
+	glType, glFormat, glInternalFormat := field(1), field(3), field(4)
+	img, info, err := newImage(glInternalFormat,
+		int(field(6)), int(field(7)), int(field(8)),
+		int(field(9)), int(field(10)), int(field(11)))
+	if err != nil {
+		return nil, err
+	}
+	if glFormat != info.format || glType != info.dataType {
+		return nil, fmt.Errorf("Format 0x%x and type 0x%x do not match internal format 0x%x", glFormat, glType, glInternalFormat)
+	}
+
+	keyValueSize := int64(field(12))
+	if _, err := io.CopyN(io.Discard, r, keyValueSize); err != nil {
+		return nil, fmt.Errorf("Truncated key/value data: %v", err)
+	}
+
+	layers := img.Layers()
+	for level := range img.Levels {
+		dst := &img.Levels[level]
+
+		var sizeBuf [4]byte
+		if _, err := io.ReadFull(r, sizeBuf[:]); err != nil {
+			return nil, fmt.Errorf("Truncated mipmap level %d: %v", level, err)
+		}
+		imageSize := int(enc.Uint32(sizeBuf[:]))
+
+		// rows of uncompressed formats are aligned to 4 bytes
+		rowSize := dst.Width * info.size
+		paddedRowSize := rowSize
+		if !info.compressed() {
+			paddedRowSize = (rowSize + 3) &^ 3
+		}
+		surfaceSize := info.levelSize(dst.Width, dst.Height) * dst.Depth
+		if !info.compressed() {
+			surfaceSize = paddedRowSize * dst.Height * dst.Depth
+		}
+
+		// imageSize is the size of a single face for cubemaps
+		// that are not arrays, for others it contains all layers;
+		// it is a multiple of 4, so faces and levels need no padding
+		surfaces, expected := 1, surfaceSize*layers
+		if img.Cubemap && img.ArraySize == 1 {
+			surfaces, expected = 6, surfaceSize
+		}
+		if imageSize != expected {
+			return nil, fmt.Errorf("Invalid image size %d of mipmap level %d, expected %d", imageSize, level, expected)
+		}
+
+		for surface := 0; surface < surfaces; surface++ {
+			data, err := texutil.ReadData(r, imageSize)
+			if err == io.EOF {
+				return nil, fmt.Errorf("Truncated mipmap level %d: expected %d bytes, got %d", level, imageSize, len(data))
+			}
+			if err != nil {
+				return nil, err
+			}
+			if paddedRowSize != rowSize {
+				data = removePadding(data, rowSize, paddedRowSize)
+			}
+			dst.Data = append(dst.Data, data...)
+		}
+	}
+
+	return img, nil
+}
+
+// removePadding removes the padding from the end of each row
+func removePadding(data []byte, rowSize, paddedRowSize int) []byte {
+	rows := len(data) / paddedRowSize
+	tight := make([]byte, 0, rows*rowSize)
+	for row := 0; row < rows; row++ {
+		tight = append(tight, data[row*paddedRowSize:row*paddedRowSize+rowSize]...)
+	}
+	return tight
+}
+
+// decode2 parses a KTX2 file after the identifier
+func decode2(r io.Reader) (*dds.Image, error) {
+	// levels are located by the level index, so
+	// the whole file is read
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < header2Size {
+		return nil, fmt.Errorf("Truncated header: %d bytes", len(data))
+	}
+
+	enc := binary.LittleEndian
+	field := func(i int) uint32 { return enc.Uint32(data[4*i:]) }
+
+	vkFormat := field(0)
+	if vkFormat == 0 {
+		return nil, errors.New("Unimplemented format VK_FORMAT_UNDEFINED")
+	}
+	internalFormat, ok := vkFormats[vkFormat]
+	if !ok {
+		return nil, fmt.Errorf("Unimplemented Vulkan format %d", vkFormat)
+	}
+	if scheme := field(8); scheme != 0 {
+		return nil, fmt.Errorf("Unimplemented supercompression scheme %d", scheme)
+	}
+
+	img, info, err := newImage(internalFormat,
+		int(field(2)), int(field(3)), int(field(4)),
+		int(field(5)), int(field(6)), int(field(7)))
+	if err != nil {
+		return nil, err
+	}
+
+	// the level index follows the header, the offsets are
+	// from the start of the file including the identifier
+	index := data[header2Size:]
+	if len(index) < 24*len(img.Levels) {
+		return nil, errors.New("Truncated level index")
+	}
+	start := uint64(len(identifier2))
+
+	layers := img.Layers()
+	for level := range img.Levels {
+		dst := &img.Levels[level]
+		offset := enc.Uint64(index[24*level:])
+		length := enc.Uint64(index[24*level+8:])
+
+		expected := uint64(info.levelSize(dst.Width, dst.Height) * dst.Depth * layers)
+		if length != expected {
+			return nil, fmt.Errorf("Invalid length %d of mipmap level %d, expected %d", length, level, expected)
+		}
+		if offset < start || offset-start > uint64(len(data)) || length > uint64(len(data))-(offset-start) {
+			return nil, fmt.Errorf("Mipmap level %d at %d is outside the file", level, offset)
+		}
+
+		begin := offset - start
+		dst.Data = data[begin : begin+length : begin+length]
+	}
+
+	return img, nil
+}
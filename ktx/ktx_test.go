@@ -0,0 +1,302 @@
+package ktx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+
+	"github.com/egonelbre/opengl-tutorial.org/dds"
+)
+
+// testHeader1 describes a KTX 1.1 file header for building test files
+type testHeader1 struct {
+	endianness     uint32
+	glType         uint32
+	glFormat       uint32
+	internalFormat uint32
+	width, height  int
+	depth          int
+	arraySize      int
+	faces          int
+	levels         int
+	keyValue       []byte
+}
+
+// file appends the data after the header, each level
+// must start with its image size
+func (h testHeader1) file(data ...[]byte) []byte {
+	if h.endianness == 0 {
+		h.endianness = endianness
+	}
+	buf := []byte(identifier1)
+	buf = append(buf, uint32s(
+		h.endianness, h.glType, 1, h.glFormat, h.internalFormat, h.glFormat,
+		uint32(h.width), uint32(h.height), uint32(h.depth),
+		uint32(h.arraySize), uint32(h.faces), uint32(h.levels),
+		uint32(len(h.keyValue)))...)
+	buf = append(buf, h.keyValue...)
+	for _, d := range data {
+		buf = append(buf, d...)
+	}
+	return buf
+}
+
+func rgb8Header(width, height int) testHeader1 {
+	return testHeader1{
+		glType:         gl.UNSIGNED_BYTE,
+		glFormat:       gl.RGB,
+		internalFormat: gl.RGB8,
+		width:          width,
+		height:         height,
+		faces:          1,
+		levels:         1,
+	}
+}
+
+// testHeader2 describes a KTX2 file header for building test files
+type testHeader2 struct {
+	vkFormat      uint32
+	width, height int
+	depth         int
+	arraySize     int
+	faces         int
+	levels        int
+	scheme        uint32
+	// index contains the offset and length of each level
+	index [][2]uint64
+}
+
+// file appends the data after the header and the level index
+func (h testHeader2) file(data ...[]byte) []byte {
+	enc := binary.LittleEndian
+	buf := []byte(identifier2)
+	buf = append(buf, uint32s(
+		h.vkFormat, 1, uint32(h.width), uint32(h.height), uint32(h.depth),
+		uint32(h.arraySize), uint32(h.faces), uint32(h.levels), h.scheme,
+		0, 0, 0, 0)...)
+	buf = append(buf, make([]byte, 16)...)
+	for _, entry := range h.index {
+		var level [24]byte
+		enc.PutUint64(level[0:], entry[0])
+		enc.PutUint64(level[8:], entry[1])
+		enc.PutUint64(level[16:], entry[1])
+		buf = append(buf, level[:]...)
+	}
+	for _, d := range data {
+		buf = append(buf, d...)
+	}
+	return buf
+}
+
+// bc1Header2 returns a header for a 8x8 BC1 texture with two levels,
+// the levels are stored smallest first after the index
+func bc1Header2() testHeader2 {
+	start := uint64(len(identifier2) + header2Size + 2*24)
+	return testHeader2{
+		vkFormat: 131,
+		width:    8,
+		height:   8,
+		faces:    1,
+		levels:   2,
+		index:    [][2]uint64{{start + 8, 32}, {start, 8}},
+	}
+}
+
+func uint32s(values ...uint32) []byte {
+	data := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(data[4*i:], v)
+	}
+	return data
+}
+
+// sequence returns n bytes counting up from start
+func sequence(start byte, n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = start + byte(i)
+	}
+	return data
+}
+
+// padded returns rows of rowSize bytes counting up from start,
+// each followed by padding bytes of 0xEE
+func padded(start byte, rows, rowSize, padding int) []byte {
+	var data []byte
+	for row := 0; row < rows; row++ {
+		data = append(data, sequence(start+byte(row*rowSize), rowSize)...)
+		data = append(data, bytes.Repeat([]byte{0xEE}, padding)...)
+	}
+	return data
+}
+
+func with1(h testHeader1, change func(h *testHeader1)) testHeader1 {
+	change(&h)
+	return h
+}
+
+func with2(h testHeader2, change func(h *testHeader2)) testHeader2 {
+	change(&h)
+	return h
+}
+
+func TestDecode(t *testing.T) {
+	cube := rgb8Header(2, 2)
+	cube.faces = 6
+
+	cubeArray := cube
+	cubeArray.arraySize = 2
+
+	bc1 := testHeader1{
+		internalFormat: gl.COMPRESSED_RGB_S3TC_DXT1_EXT,
+		width:          8,
+		height:         8,
+		faces:          1,
+		levels:         2,
+		keyValue:       []byte("key\x00value\x00\x00\x00"),
+	}
+
+	type level struct{ width, height, depth, size int }
+	tests := []struct {
+		name    string
+		file    []byte
+		format  dds.DXGIFormat
+		cubemap bool
+		layers  int
+		levels  []level
+		data    []byte
+	}{
+		{
+			name:   "KTX1 row padding",
+			file:   rgb8Header(3, 2).file(uint32s(24), padded(0, 2, 9, 3)),
+			format: dds.UNKNOWN, layers: 1,
+			levels: []level{{3, 2, 1, 18}},
+			data:   sequence(0, 18),
+		},
+		{
+			name:   "KTX1 aligned rows",
+			file:   with1(rgb8Header(4, 1), func(h *testHeader1) { h.height = 0 }).file(uint32s(12), sequence(0, 12)),
+			format: dds.UNKNOWN, layers: 1,
+			levels: []level{{4, 1, 1, 12}},
+			data:   sequence(0, 12),
+		},
+		{
+			name:   "KTX1 cubemap",
+			file:   cube.file(uint32s(16), padded(0, 6*2, 6, 2)),
+			format: dds.UNKNOWN, cubemap: true, layers: 6,
+			levels: []level{{2, 2, 1, 6 * 12}},
+			data:   sequence(0, 6*12),
+		},
+		{
+			name:   "KTX1 cubemap array",
+			file:   cubeArray.file(uint32s(2*6*16), padded(0, 2*6*2, 6, 2)),
+			format: dds.UNKNOWN, cubemap: true, layers: 12,
+			levels: []level{{2, 2, 1, 12 * 12}},
+			data:   sequence(0, 12*12),
+		},
+		{
+			name:   "KTX1 compressed mipmaps",
+			file:   bc1.file(uint32s(32), sequence(0, 32), uint32s(8), sequence(32, 8)),
+			format: dds.BC1_UNORM, layers: 1,
+			levels: []level{{8, 8, 1, 32}, {4, 4, 1, 8}},
+			data:   sequence(0, 40),
+		},
+		{
+			name:   "KTX2 level index",
+			file:   bc1Header2().file(sequence(32, 8), sequence(0, 32)),
+			format: dds.BC1_UNORM, layers: 1,
+			levels: []level{{8, 8, 1, 32}, {4, 4, 1, 8}},
+			data:   sequence(0, 40),
+		},
+		{
+			name: "KTX2 array",
+			file: testHeader2{
+				vkFormat: 37, width: 1, height: 1, arraySize: 3, faces: 1,
+				index: [][2]uint64{{uint64(len(identifier2) + header2Size + 24), 12}},
+			}.file(sequence(0, 12)),
+			format: dds.R8G8B8A8_UNORM, layers: 3,
+			levels: []level{{1, 1, 1, 12}},
+			data:   sequence(0, 12),
+		},
+	}
+
+	for _, test := range tests {
+		img, err := Decode(bytes.NewReader(test.file))
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if img.DXGIFormat != test.format || img.Cubemap != test.cubemap || img.Layers() != test.layers {
+			t.Errorf("%v: got %v cubemap %v with %d layers, expected %v cubemap %v with %d", test.name,
+				img.DXGIFormat, img.Cubemap, img.Layers(), test.format, test.cubemap, test.layers)
+		}
+
+		var got []level
+		var data []byte
+		for _, l := range img.Levels {
+			got = append(got, level{l.Width, l.Height, l.Depth, len(l.Data)})
+			data = append(data, l.Data...)
+		}
+		if !reflect.DeepEqual(got, test.levels) {
+			t.Errorf("%v: got levels %v, expected %v", test.name, got, test.levels)
+		}
+		if !bytes.Equal(data, test.data) {
+			t.Errorf("%v: got data %v, expected %v", test.name, data, test.data)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	rgb8 := rgb8Header(2, 2)
+	cube := with1(rgb8, func(h *testHeader1) { h.faces = 6 })
+	bc1 := bc1Header2()
+	levels := [][]byte{sequence(32, 8), sequence(0, 32)}
+
+	tests := []struct {
+		name string
+		file []byte
+		err  string
+	}{
+		{"magic", append([]byte("\xABKTX 30\xBB\r\n\x1A\n"), rgb8.file()[12:]...), "Not KTX file"},
+
+		{"KTX1 truncated header", rgb8.file()[:40], "Truncated header"},
+		{"KTX1 big-endian", with1(rgb8, func(h *testHeader1) { h.endianness = 0x01020304 }).file(), "Unimplemented big-endian file"},
+		{"KTX1 endianness", with1(rgb8, func(h *testHeader1) { h.endianness = 1 }).file(), "Invalid endianness 0x1"},
+		{"KTX1 internal format", with1(rgb8, func(h *testHeader1) { h.internalFormat = 0x1234 }).file(), "Unimplemented internal format 0x1234"},
+		{"KTX1 format", with1(rgb8, func(h *testHeader1) { h.glFormat = gl.RGBA }).file(), "Format 0x1908 and type 0x1401 do not match internal format 0x8051"},
+		{"KTX1 zero size", with1(rgb8, func(h *testHeader1) { h.width = 0 }).file(), "Invalid size 0x2x1"},
+		{"KTX1 too large", with1(rgb8, func(h *testHeader1) { h.width = 1 << 20 }).file(), "Invalid size 1048576x2x1"},
+		{"KTX1 faces", with1(rgb8, func(h *testHeader1) { h.faces = 3 }).file(), "Invalid number of faces 3"},
+		{"KTX1 cubemap square", with1(cube, func(h *testHeader1) { h.width = 4 }).file(), "Cubemap faces are not square 4x2"},
+		{"KTX1 volume cubemap", with1(cube, func(h *testHeader1) { h.depth = 2 }).file(), "Volume texture arrays and cubemaps are not supported"},
+		{"KTX1 mipmap count", with1(rgb8, func(h *testHeader1) { h.levels = 3 }).file(), "Invalid mipmap count 3 for 2x2x1, expected at most 2"},
+		{"KTX1 truncated key/value", with1(rgb8, func(h *testHeader1) { h.keyValue = make([]byte, 8) }).file()[:len(identifier1)+header1Size+4], "Truncated key/value data"},
+		{"KTX1 truncated image size", rgb8.file(uint32s(8)[:2]), "Truncated mipmap level 0"},
+		{"KTX1 image size", rgb8.file(uint32s(12), sequence(0, 12)), "Invalid image size 12 of mipmap level 0, expected 16"},
+		{"KTX1 cubemap image size", cube.file(uint32s(6*16), sequence(0, 6*16)), "Invalid image size 96 of mipmap level 0, expected 16"},
+		{"KTX1 truncated data", rgb8.file(uint32s(16), sequence(0, 5)), "Truncated mipmap level 0: expected 16 bytes, got 5"},
+		{"KTX1 truncated face", cube.file(uint32s(16), sequence(0, 5*16+3)), "Truncated mipmap level 0: expected 16 bytes, got 3"},
+
+		{"KTX2 truncated header", bc1.file()[:len(identifier2)+60], "Truncated header: 60 bytes"},
+		{"KTX2 undefined format", with2(bc1, func(h *testHeader2) { h.vkFormat = 0 }).file(levels...), "Unimplemented format VK_FORMAT_UNDEFINED"},
+		{"KTX2 format", with2(bc1, func(h *testHeader2) { h.vkFormat = 1000 }).file(levels...), "Unimplemented Vulkan format 1000"},
+		{"KTX2 supercompression", with2(bc1, func(h *testHeader2) { h.scheme = 2 }).file(levels...), "Unimplemented supercompression scheme 2"},
+		{"KTX2 truncated index", bc1.file()[:len(identifier2)+header2Size+30], "Truncated level index"},
+		{"KTX2 length", with2(bc1, func(h *testHeader2) { h.index = [][2]uint64{{h.index[0][0], 16}, h.index[1]} }).file(levels...), "Invalid length 16 of mipmap level 0, expected 32"},
+		{"KTX2 offset before file", with2(bc1, func(h *testHeader2) { h.index = [][2]uint64{{4, 32}, h.index[1]} }).file(levels...), "Mipmap level 0 at 4 is outside the file"},
+		{"KTX2 offset after file", with2(bc1, func(h *testHeader2) { h.index = [][2]uint64{h.index[0], {1 << 40, 8}} }).file(levels...), "Mipmap level 1 at 1099511627776 is outside the file"},
+		{"KTX2 offset overflow", with2(bc1, func(h *testHeader2) { h.index = [][2]uint64{{^uint64(0) - 8, 32}, h.index[1]} }).file(levels...), "Mipmap level 0 at 18446744073709551607 is outside the file"},
+		{"KTX2 truncated data", bc1.file(levels[0], levels[1][:31]), "Mipmap level 0 at 136 is outside the file"},
+	}
+
+	for _, test := range tests {
+		_, err := Decode(bytes.NewReader(test.file))
+		if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("%v: got error %v, expected %q", test.name, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package obj
+
+import "github.com/go-gl/mathgl/mgl32"
+
+// Box is an axis-aligned bounding box.
+type Box struct {
+	Min mgl32.Vec3
+	Max mgl32.Vec3
+}
+
+func (box Box) Center() mgl32.Vec3 { return box.Min.Add(box.Max).Mul(0.5) }
+func (box Box) Size() mgl32.Vec3   { return box.Max.Sub(box.Min) }
+
+// Sphere is a bounding sphere.
+type Sphere struct {
+	Center mgl32.Vec3
+	Radius float32
+}
+
+// Stats contains the mesh statistics.
+type Stats struct {
+	Vertices   int
+	Triangles  int
+	Degenerate int // triangles with zero area
+}
+
+// Bounds returns the bounding box of the vertices.
+func (data *Data) Bounds() Box {
+	count := len(data.Vertex) / 3
+	if count == 0 {
+		return Box{}
+	}
+
+	box := Box{data.position(0), data.position(0)}
+	for i := 1; i < count; i++ {
+		p := data.position(i)
+		for k := range p {
+			box.Min[k] = min32(box.Min[k], p[k])
+			box.Max[k] = max32(box.Max[k], p[k])
+		}
+	}
+	return box
+}
+
+// BoundingSphere returns a bounding sphere of the vertices,
+// computed with Ritter's algorithm; it is not always the smallest.
+func (data *Data) BoundingSphere() Sphere {
+	count := len(data.Vertex) / 3
+	if count == 0 {
+		return Sphere{}
+	}
+
+	// find two distant points to start with
+	farthest := func(from mgl32.Vec3) mgl32.Vec3 {
+		best, bestDist := from, float32(0)
+		for i := 0; i < count; i++ {
+			p := data.position(i)
+			if dist := p.Sub(from).Len(); dist > bestDist {
+				best, bestDist = p, dist
+			}
+		}
+		return best
+	}
+	a := farthest(data.position(0))
+	b := farthest(a)
+
+	sphere := Sphere{
+		Center: a.Add(b).Mul(0.5),
+		Radius: b.Sub(a).Len() / 2,
+	}
+
+	// grow the sphere to include the points outside
+	for i := 0; i < count; i++ {
+		p := data.position(i)
+		dist := p.Sub(sphere.Center).Len()
+		if dist <= sphere.Radius {
+			continue
+		}
+		radius := (sphere.Radius + dist) / 2
+		sphere.Center = sphere.Center.Add(p.Sub(sphere.Center).Mul((radius - sphere.Radius) / dist))
+		sphere.Radius = radius
+	}
+	return sphere
+}
+
+// Stats returns the vertex, triangle and degenerate triangle counts.
+func (data *Data) Stats() Stats {
+	stats := Stats{
+		Vertices:  len(data.Vertex) / 3,
+		Triangles: data.ElementCount() / 3,
+	}
+
+	for t := 0; t < stats.Triangles; t++ {
+		a := data.position(data.vertexIndex(t * 3))
+		b := data.position(data.vertexIndex(t*3 + 1))
+		c := data.position(data.vertexIndex(t*3 + 2))
+
+		ab, ac := b.Sub(a), c.Sub(a)
+		longest := max32(max32(ab.Len(), ac.Len()), c.Sub(b).Len())
+		if ab.Cross(ac).Len() <= 1e-6*longest*longest {
+			stats.Degenerate++
+		}
+	}
+	return stats
+}
+
+// Recenter moves the vertices such that the center of
+// the bounding box is at the origin.
+func (data *Data) Recenter() {
+	data.transform(data.Bounds().Center(), 1)
+}
+
+// Normalize moves the center of the bounding box to the origin
+// and scales the vertices uniformly such that the largest side
+// of the bounding box is 1.
+func (data *Data) Normalize() {
+	box := data.Bounds()
+	size := box.Size()
+
+	largest := max32(max32(size[0], size[1]), size[2])
+	scale := float32(1)
+	if largest > 0 {
+		scale = 1 / largest
+	}
+	data.transform(box.Center(), scale)
+}
+
+// transform translates the vertices by -center and scales them
+func (data *Data) transform(center mgl32.Vec3, scale float32) {
+	for i := 0; i+2 < len(data.Vertex); i += 3 {
+		for k := range center {
+			data.Vertex[i+k] = (data.Vertex[i+k] - center[k]) * scale
+		}
+	}
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,115 @@
+package obj
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// cornerData returns two triangles over the corners of
+// the box from 1,2,3 to 5,4,4, the second one degenerate
+func cornerData() *Data {
+	return &Data{
+		Vertex: []float32{
+			1, 2, 3, 5, 2, 3, 1, 4, 4,
+			5, 4, 4, 5, 4, 4, 1, 2, 3,
+		},
+	}
+}
+
+func TestBounds(t *testing.T) {
+	box := cornerData().Bounds()
+	expected := Box{mgl32.Vec3{1, 2, 3}, mgl32.Vec3{5, 4, 4}}
+	if box != expected {
+		t.Errorf("got %v, expected %v", box, expected)
+	}
+	if center := box.Center(); center != (mgl32.Vec3{3, 3, 3.5}) {
+		t.Errorf("got center %v", center)
+	}
+	if size := box.Size(); size != (mgl32.Vec3{4, 2, 1}) {
+		t.Errorf("got size %v", size)
+	}
+
+	if box := (&Data{}).Bounds(); box != (Box{}) {
+		t.Errorf("got %v for empty data", box)
+	}
+}
+
+func TestBoundingSphere(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cube := &Data{}
+	for i := 0; i < 1000; i++ {
+		cube.Vertex = append(cube.Vertex, rng.Float32()*2-1, rng.Float32()*2-1, rng.Float32()*2-1)
+	}
+	for _, corner := range [][3]float32{{-1, -1, -1}, {1, 1, 1}, {1, -1, 1}, {-1, 1, -1}} {
+		cube.Vertex = append(cube.Vertex, corner[:]...)
+	}
+
+	for _, data := range []*Data{cornerData(), cube} {
+		sphere := data.BoundingSphere()
+		for i := 0; i < len(data.Vertex)/3; i++ {
+			if dist := data.position(i).Sub(sphere.Center).Len(); dist > sphere.Radius*(1+1e-6) {
+				t.Errorf("vertex %d is outside %v at %v", i, sphere, dist)
+			}
+		}
+
+		// half of the diagonal of the bounding box is enough
+		size := data.Bounds().Size()
+		if smallest := size.Len() / 2; sphere.Radius > smallest*1.05 {
+			t.Errorf("got radius %v, expected about %v", sphere.Radius, smallest)
+		}
+	}
+
+	if sphere := (&Data{}).BoundingSphere(); sphere != (Sphere{}) {
+		t.Errorf("got %v for empty data", sphere)
+	}
+}
+
+func TestStats(t *testing.T) {
+	expected := Stats{Vertices: 6, Triangles: 2, Degenerate: 1}
+	if stats := cornerData().Stats(); stats != expected {
+		t.Errorf("got %+v, expected %+v", stats, expected)
+	}
+
+	indexed := cornerData().Indexed()
+	expected.Vertices = 4
+	if stats := indexed.Stats(); stats != expected {
+		t.Errorf("indexed: got %+v, expected %+v", stats, expected)
+	}
+}
+
+func TestRecenter(t *testing.T) {
+	data := cornerData()
+	data.Recenter()
+	expected := []float32{
+		-2, -1, -0.5, 2, -1, -0.5, -2, 1, 0.5,
+		2, 1, 0.5, 2, 1, 0.5, -2, -1, -0.5,
+	}
+	if !reflect.DeepEqual(data.Vertex, expected) {
+		t.Errorf("got %v, expected %v", data.Vertex, expected)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	data := cornerData()
+	data.Normalize()
+	expected := []float32{
+		-0.5, -0.25, -0.125, 0.5, -0.25, -0.125, -0.5, 0.25, 0.125,
+		0.5, 0.25, 0.125, 0.5, 0.25, 0.125, -0.5, -0.25, -0.125,
+	}
+	if !reflect.DeepEqual(data.Vertex, expected) {
+		t.Errorf("got %v, expected %v", data.Vertex, expected)
+	}
+
+	// a single point is moved to the origin without scaling
+	point := &Data{Vertex: []float32{1, 2, 3, 1, 2, 3, 1, 2, 3}}
+	point.Normalize()
+	for _, v := range point.Vertex {
+		if v != 0 {
+			t.Errorf("got %v, expected zeros", point.Vertex)
+			break
+		}
+	}
+}
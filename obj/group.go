@@ -0,0 +1,182 @@
+package obj
+
+// Group is a named range of elements.
+type Group struct {
+	Name  string
+	Start int
+	Count int
+}
+
+// Object returns the faces of the named object as a separate mesh.
+func (data *Data) Object(name string) (*Data, bool) {
+	return data.subset(findGroups(data.Objects, name))
+}
+
+// Group returns the faces of the named group as a separate mesh.
+func (data *Data) Group(name string) (*Data, bool) {
+	return data.subset(findGroups(data.Groups, name))
+}
+
+// ObjectNames returns the names of the objects in the order they appear.
+func (data *Data) ObjectNames() []string { return groupNames(data.Objects) }
+
+// GroupNames returns the names of the groups in the order they appear.
+func (data *Data) GroupNames() []string { return groupNames(data.Groups) }
+
+func findGroups(groups []Group, name string) []Group {
+	var found []Group
+	for _, group := range groups {
+		if group.Name == name {
+			found = append(found, group)
+		}
+	}
+	return found
+}
+
+func groupNames(groups []Group) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, group := range groups {
+		if !seen[group.Name] {
+			seen[group.Name] = true
+			names = append(names, group.Name)
+		}
+	}
+	return names
+}
+
+// subset copies the elements in ranges into a new mesh, ok is false
+// when there are no ranges
+func (data *Data) subset(ranges []Group) (result *Data, ok bool) {
+	if len(ranges) == 0 {
+		return nil, false
+	}
+
+	result = &Data{
+		MaterialLibs: data.MaterialLibs,
+		Materials:    data.Materials,
+	}
+	src, dst := data.attributes(), result.attributes()
+
+	var remap map[int]uint32
+	if data.Indices != nil {
+		remap = make(map[int]uint32)
+		result.Indices = []uint32{}
+	}
+
+	for _, r := range ranges {
+		offset := result.ElementCount() - r.Start
+
+		for e := r.Start; e < r.Start+r.Count; e++ {
+			i := data.vertexIndex(e)
+			if remap != nil {
+				if index, ok := remap[i]; ok {
+					result.Indices = append(result.Indices, index)
+					continue
+				}
+				index := uint32(len(result.Vertex) / 3)
+				remap[i] = index
+				result.Indices = append(result.Indices, index)
+			}
+			appendVertex(dst, src, i)
+		}
+
+		if data.Smoothing != nil {
+			result.Smoothing = append(result.Smoothing, data.Smoothing[r.Start/3:(r.Start+r.Count)/3]...)
+		}
+
+		for _, submesh := range data.Submeshes {
+			if _, count := intersect(submesh.Start, submesh.Count, r); count > 0 {
+				result.extendSubmesh(submesh.Material, count)
+			}
+		}
+		result.Objects = clipGroups(result.Objects, data.Objects, r, offset)
+		result.Groups = clipGroups(result.Groups, data.Groups, r, offset)
+	}
+
+	return result, true
+}
+
+// clipGroups appends the parts of groups that are inside r to dst
+func clipGroups(dst, groups []Group, r Group, offset int) []Group {
+	for _, group := range groups {
+		start, count := intersect(group.Start, group.Count, r)
+		if count <= 0 {
+			continue
+		}
+		dst = appendGroup(dst, Group{
+			Name:  group.Name,
+			Start: start + offset,
+			Count: count,
+		})
+	}
+	return dst
+}
+
+// appendGroup appends group to groups, merging it with an
+// adjacent group with the same name
+func appendGroup(groups []Group, group Group) []Group {
+	for i := range groups {
+		last := &groups[i]
+		if last.Name == group.Name && last.Start+last.Count == group.Start {
+			last.Count += group.Count
+			return groups
+		}
+	}
+	return append(groups, group)
+}
+
+// intersect returns the part of range start, count that is inside r
+func intersect(start, count int, r Group) (int, int) {
+	end := start + count
+	if start < r.Start {
+		start = r.Start
+	}
+	if end > r.Start+r.Count {
+		end = r.Start + r.Count
+	}
+	return start, end - start
+}
+
+// activeGroups tracks the groups that new faces are added to
+type activeGroups struct {
+	names []string
+	first int // index of the first active group, -1 when not added
+}
+
+func (active *activeGroups) set(names []string) {
+	if equalNames(active.names, names) {
+		return
+	}
+	active.names = names
+	active.first = -1
+}
+
+// extend adds count elements starting at start to the active groups
+func (active *activeGroups) extend(groups []Group, start, count int) []Group {
+	if len(active.names) == 0 {
+		return groups
+	}
+	if active.first < 0 {
+		active.first = len(groups)
+		for _, name := range active.names {
+			groups = append(groups, Group{Name: name, Start: start})
+		}
+	}
+	for i := active.first; i < len(groups); i++ {
+		groups[i].Count += count
+	}
+	return groups
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package obj
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const groupSource = `
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+f 1 2 3
+o first
+g a b
+f 1 2 3
+f 1 3 4
+g b
+f 1 2 4
+o second
+f 2 3 4
+g a
+f 1 2 3 4
+`
+
+func TestLoadGroups(t *testing.T) {
+	data, err := Load(strings.NewReader(groupSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objects := []Group{{"first", 3, 9}, {"second", 12, 9}}
+	if !reflect.DeepEqual(data.Objects, objects) {
+		t.Errorf("got objects %v, expected %v", data.Objects, objects)
+	}
+	// repeating the group statement starts a new range
+	groups := []Group{{"a", 3, 6}, {"b", 3, 6}, {"b", 9, 6}, {"a", 15, 6}}
+	if !reflect.DeepEqual(data.Groups, groups) {
+		t.Errorf("got groups %v, expected %v", data.Groups, groups)
+	}
+
+	if names := data.ObjectNames(); !reflect.DeepEqual(names, []string{"first", "second"}) {
+		t.Errorf("got object names %v", names)
+	}
+	if names := data.GroupNames(); !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Errorf("got group names %v", names)
+	}
+}
+
+func TestSubset(t *testing.T) {
+	data, err := Load(strings.NewReader(groupSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		subset  func(data *Data) (*Data, bool)
+		start   []int // starts of the ranges in data
+		objects []Group
+		groups  []Group
+	}{
+		{
+			name:    "object",
+			subset:  func(data *Data) (*Data, bool) { return data.Object("first") },
+			start:   []int{3, 6, 9},
+			objects: []Group{{"first", 0, 9}},
+			groups:  []Group{{"a", 0, 6}, {"b", 0, 9}},
+		},
+		{
+			name:    "group",
+			subset:  func(data *Data) (*Data, bool) { return data.Group("b") },
+			start:   []int{3, 6, 9, 12},
+			objects: []Group{{"first", 0, 9}, {"second", 9, 3}},
+			groups:  []Group{{"a", 0, 6}, {"b", 0, 12}},
+		},
+	}
+
+	for _, test := range tests {
+		for _, indexed := range []bool{false, true} {
+			src := data
+			if indexed {
+				src = data.Indexed()
+			}
+			subset, ok := test.subset(src)
+			if !ok {
+				t.Errorf("%v: not found", test.name)
+				continue
+			}
+			if (subset.Indices != nil) != indexed {
+				t.Errorf("%v: got indices %v, expected indexed %v", test.name, subset.Indices, indexed)
+			}
+			subset.expand()
+
+			var vertex []float32
+			for _, start := range test.start {
+				vertex = append(vertex, data.Vertex[3*start:3*(start+3)]...)
+			}
+			if !reflect.DeepEqual(subset.Vertex, vertex) {
+				t.Errorf("%v: got vertices %v, expected %v", test.name, subset.Vertex, vertex)
+			}
+			if !reflect.DeepEqual(subset.Objects, test.objects) {
+				t.Errorf("%v: got objects %v, expected %v", test.name, subset.Objects, test.objects)
+			}
+			if !reflect.DeepEqual(subset.Groups, test.groups) {
+				t.Errorf("%v: got groups %v, expected %v", test.name, subset.Groups, test.groups)
+			}
+			if count := subset.ElementCount(); len(subset.Submeshes) != 1 || subset.Submeshes[0].Count != count {
+				t.Errorf("%v: got submeshes %v for %d elements", test.name, subset.Submeshes, count)
+			}
+		}
+	}
+
+	if _, ok := data.Group("missing"); ok {
+		t.Errorf("missing group found")
+	}
+}
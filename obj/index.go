@@ -0,0 +1,129 @@
+package obj
+
+import "math"
+
+// Indexed returns a copy of data where identical vertices have been
+// merged and triangles are described by Indices.
+func (data *Data) Indexed() *Data {
+	result := data.withoutVertices()
+
+	// vertexKey must fit all the attributes
+	type vertexKey [15]float32
+
+	src, dst := data.attributes(), result.attributes()
+
+	count := data.ElementCount()
+	lookup := make(map[vertexKey]uint32, count)
+	result.Indices = make([]uint32, 0, count)
+
+	for e := 0; e < count; e++ {
+		i := data.vertexIndex(e)
+
+		var key vertexKey
+		n := 0
+		for _, attr := range src {
+			if *attr.values != nil {
+				n += copy(key[n:], attr.vertex(i))
+			}
+		}
+
+		index, ok := lookup[key]
+		if !ok {
+			index = uint32(len(result.Vertex) / 3)
+			lookup[key] = index
+			appendVertex(dst, src, i)
+		}
+		result.Indices = append(result.Indices, index)
+	}
+
+	return result
+}
+
+// Indices16 returns Indices as uint16, ok is false when
+// there are too many vertices to be addressed with 16 bits.
+func (data *Data) Indices16() (indices []uint16, ok bool) {
+	if len(data.Vertex)/3 > math.MaxUint16+1 {
+		return nil, false
+	}
+	indices = make([]uint16, len(data.Indices))
+	for i, index := range data.Indices {
+		indices[i] = uint16(index)
+	}
+	return indices, true
+}
+
+// ElementCount returns the number of vertices to draw,
+// i.e. the count for gl.DrawArrays or gl.DrawElements.
+func (data *Data) ElementCount() int {
+	if data.Indices != nil {
+		return len(data.Indices)
+	}
+	return len(data.Vertex) / 3
+}
+
+// vertexIndex returns the vertex used by the e-th element
+func (data *Data) vertexIndex(e int) int {
+	if data.Indices != nil {
+		return int(data.Indices[e])
+	}
+	return e
+}
+
+// expand converts indexed data into non-indexed data
+func (data *Data) expand() {
+	if data.Indices == nil {
+		return
+	}
+
+	result := data.withoutVertices()
+	src, dst := data.attributes(), result.attributes()
+	for _, index := range data.Indices {
+		appendVertex(dst, src, int(index))
+	}
+	*data = *result
+}
+
+// withoutVertices returns a copy of data without
+// vertex attributes and indices
+func (data *Data) withoutVertices() *Data {
+	return &Data{
+		Submeshes:    data.Submeshes,
+		MaterialLibs: data.MaterialLibs,
+		Materials:    data.Materials,
+
+		Objects:   data.Objects,
+		Groups:    data.Groups,
+		Smoothing: data.Smoothing,
+	}
+}
+
+// attribute is a per-vertex array with size components per vertex
+type attribute struct {
+	values *[]float32
+	size   int
+}
+
+func (attr attribute) vertex(i int) []float32 {
+	return (*attr.values)[i*attr.size : (i+1)*attr.size]
+}
+
+// attributes returns all the per-vertex arrays of data
+func (data *Data) attributes() []attribute {
+	return []attribute{
+		{&data.Vertex, 3},
+		{&data.UV, 2},
+		{&data.Normal, 3},
+		{&data.Tangent, 4},
+		{&data.Bitangent, 3},
+	}
+}
+
+// appendVertex appends the i-th vertex of src to dst,
+// attributes missing from src are skipped
+func appendVertex(dst, src []attribute, i int) {
+	for k, attr := range src {
+		if *attr.values != nil {
+			*dst[k].values = append(*dst[k].values, attr.vertex(i)...)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package obj
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIndexed(t *testing.T) {
+	const src = `
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+vt 0 0
+vt 1 0
+vt 1 1
+vt 0 1
+vt 0.5 0.5
+vn 0 0 1
+f 1/1/1 2/2/1 3/3/1 4/4/1
+f 1/5/1 2/2/1 3/3/1
+`
+	data, err := LoadWithOptions(strings.NewReader(src), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexed := data.Indexed()
+	// the corner with a different uv is not merged
+	expected := []uint32{0, 1, 2, 0, 2, 3, 4, 1, 2}
+	if !reflect.DeepEqual(indexed.Indices, expected) {
+		t.Errorf("got indices %v, expected %v", indexed.Indices, expected)
+	}
+	if len(indexed.Vertex) != 5*3 || len(indexed.UV) != 5*2 || len(indexed.Normal) != 5*3 {
+		t.Errorf("got %d vertices, %d uvs and %d normals, expected 5", len(indexed.Vertex)/3, len(indexed.UV)/2, len(indexed.Normal)/3)
+	}
+	if indexed.ElementCount() != data.ElementCount() {
+		t.Errorf("got %d elements, expected %d", indexed.ElementCount(), data.ElementCount())
+	}
+	if !reflect.DeepEqual(indexed.Submeshes, data.Submeshes) {
+		t.Errorf("got submeshes %v, expected %v", indexed.Submeshes, data.Submeshes)
+	}
+
+	// indexing indexed data does not change it
+	if again := indexed.Indexed(); !reflect.DeepEqual(again, indexed) {
+		t.Errorf("indexing twice differs:\n%+v\n%+v", again, indexed)
+	}
+
+	// expanding restores the triangles
+	expanded := *indexed
+	expanded.expand()
+	if !reflect.DeepEqual(expanded.Vertex, data.Vertex) || !reflect.DeepEqual(expanded.UV, data.UV) || !reflect.DeepEqual(expanded.Normal, data.Normal) {
+		t.Errorf("expanded data differs:\n%+v\n%+v", expanded, data)
+	}
+}
+
+func TestIndices16(t *testing.T) {
+	data := &Data{
+		Vertex:  make([]float32, 3*65536),
+		Indices: []uint32{0, 1, 65535},
+	}
+	indices, ok := data.Indices16()
+	if !ok || !reflect.DeepEqual(indices, []uint16{0, 1, 65535}) {
+		t.Errorf("got %v, %v, expected [0 1 65535]", indices, ok)
+	}
+
+	data.Vertex = make([]float32, 3*65537)
+	if indices, ok := data.Indices16(); ok || indices != nil {
+		t.Errorf("got %v, %v for 65537 vertices, expected failure", indices, ok)
+	}
+}
@@ -0,0 +1,154 @@
+package obj
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Material describes a material from a Wavefront .mtl file.
+//
+// Texture maps are file names as written in the .mtl file,
+// usually relative to the location of the .mtl file.
+type Material struct {
+	Name string
+
+	Ambient   mgl32.Vec3 // Ka
+	Diffuse   mgl32.Vec3 // Kd
+	Specular  mgl32.Vec3 // Ks
+	Shininess float32    // Ns
+	Dissolve  float32    // d, 1 is fully opaque
+	Illum     int        // illum
+
+	DiffuseMap  string // map_Kd
+	SpecularMap string // map_Ks
+	BumpMap     string // map_Bump or bump
+	AlphaMap    string // map_d
+}
+
+// NewMaterial returns a material with the default values.
+func NewMaterial(name string) *Material {
+	return &Material{
+		Name:     name,
+		Diffuse:  mgl32.Vec3{0.8, 0.8, 0.8},
+		Dissolve: 1,
+	}
+}
+
+// LoadMaterialsFile loads the materials from a .mtl file.
+func LoadMaterialsFile(filename string) (map[string]*Material, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return LoadMaterials(bufio.NewReader(file))
+}
+
+// LoadMaterials loads materials from a .mtl file, indexed by name.
+//
+// Unknown statements are ignored, texture map options are
+// skipped and only the file name is kept.
+func LoadMaterials(r io.Reader) (map[string]*Material, error) {
+	materials := make(map[string]*Material)
+	var current *Material
+
+	scanner := newScanner(r)
+	for scanner.next() {
+		fields := scanner.fields
+
+		if string(fields[0]) == "newmtl" {
+			name := joinFields(fields[1:])
+			current = NewMaterial(name)
+			materials[name] = current
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("Error at %d: %q before newmtl", scanner.line, fields[0])
+		}
+
+		var err error
+		var v [1]float32
+		switch string(fields[0]) {
+		case "Ka":
+			err = parseFloats(current.Ambient[:], fields[1:], 3)
+		case "Kd":
+			err = parseFloats(current.Diffuse[:], fields[1:], 3)
+		case "Ks":
+			err = parseFloats(current.Specular[:], fields[1:], 3)
+		case "Ns":
+			err = parseFloats(v[:1], fields[1:], 1)
+			current.Shininess = v[0]
+		case "d":
+			err = parseFloats(v[:1], fields[1:], 1)
+			current.Dissolve = v[0]
+		case "Tr":
+			err = parseFloats(v[:1], fields[1:], 1)
+			current.Dissolve = 1 - v[0]
+		case "illum":
+			current.Illum, err = parseInt(fields[len(fields)-1])
+		case "map_Kd":
+			current.DiffuseMap, err = parseTextureMap(fields[1:])
+		case "map_Ks":
+			current.SpecularMap, err = parseTextureMap(fields[1:])
+		case "map_Bump", "map_bump", "bump":
+			current.BumpMap, err = parseTextureMap(fields[1:])
+		case "map_d":
+			current.AlphaMap, err = parseTextureMap(fields[1:])
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Error at %d: %v", scanner.line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Error at %d: %v", scanner.line, err)
+	}
+
+	return materials, nil
+}
+
+// textureOptionArgs is the number of arguments for texture map options,
+// -1 means up to three optional numbers
+var textureOptionArgs = map[string]int{
+	"-blendu": 1, "-blendv": 1, "-cc": 1, "-clamp": 1,
+	"-texres": 1, "-imfchan": 1, "-type": 1,
+	"-bm": 1, "-boost": 1,
+	"-mm": 2,
+	"-o":  -1, "-s": -1, "-t": -1,
+}
+
+// parseTextureMap skips the options of a texture map statement
+// and returns the file name
+func parseTextureMap(rawFields [][]byte) (string, error) {
+	fields := fieldStrings(rawFields)
+	for len(fields) > 0 {
+		args, ok := textureOptionArgs[fields[0]]
+		if !ok {
+			break
+		}
+		fields = fields[1:]
+
+		if args < 0 {
+			for args = 0; args < 3 && args < len(fields)-1; args++ {
+				if _, err := strconv.ParseFloat(fields[args], 32); err != nil {
+					break
+				}
+			}
+		}
+		if args >= len(fields) {
+			return "", fmt.Errorf("missing texture file name")
+		}
+		fields = fields[args:]
+	}
+	if len(fields) == 0 {
+		return "", fmt.Errorf("missing texture file name")
+	}
+	return strings.Join(fields, " "), nil
+}
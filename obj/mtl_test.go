@@ -0,0 +1,126 @@
+package obj
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestLoadMaterials(t *testing.T) {
+	const src = `
+# materials
+newmtl red plastic
+Ka 0.1 0.1 0.1
+Kd 1 0 0
+Ks 0.5 0.5 0.5
+Ns 96
+d 0.5
+illum 2
+map_Kd -o 0.5 0.5 -s 2 2 1 -bm 0.3 red texture.png
+map_Ks -clamp on -mm 0 1 spec.png
+map_Bump -bm 1.5 normal.png
+map_d -imfchan m alpha.png
+Ke 1 1 1
+
+newmtl glass
+Tr 0.75
+bump -o 1 bump.png
+`
+	materials, err := LoadMaterials(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]*Material{
+		"red plastic": {
+			Name:        "red plastic",
+			Ambient:     mgl32.Vec3{0.1, 0.1, 0.1},
+			Diffuse:     mgl32.Vec3{1, 0, 0},
+			Specular:    mgl32.Vec3{0.5, 0.5, 0.5},
+			Shininess:   96,
+			Dissolve:    0.5,
+			Illum:       2,
+			DiffuseMap:  "red texture.png",
+			SpecularMap: "spec.png",
+			BumpMap:     "normal.png",
+			AlphaMap:    "alpha.png",
+		},
+		"glass": {
+			Name:     "glass",
+			Diffuse:  mgl32.Vec3{0.8, 0.8, 0.8},
+			Dissolve: 0.25,
+			BumpMap:  "bump.png",
+		},
+	}
+	if !reflect.DeepEqual(materials, expected) {
+		for name, material := range materials {
+			t.Errorf("got %v: %+v", name, material)
+		}
+	}
+}
+
+func TestParseTextureMap(t *testing.T) {
+	tests := []struct {
+		fields   string
+		expected string
+	}{
+		{"texture.png", "texture.png"},
+		{"my texture.png", "my texture.png"},
+		{"-o 1 texture.png", "texture.png"},
+		{"-o 1 2 texture.png", "texture.png"},
+		{"-s 1 2 3 texture.png", "texture.png"},
+		{"-t 1 2 3 4.png", "4.png"},
+		{"-mm 0.1 2 -blendu off -blendv on texture.png", "texture.png"},
+		{"-cc on -texres 512 -type sphere -boost 2 texture.png", "texture.png"},
+		{"-o 1 2 3 4 5.png", "4 5.png"},
+		// the last field is the file name even when it is a number
+		{"-o 1", "1"},
+		{"-bm 1", ""},
+		{"-mm 0", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		got, err := parseTextureMap(splitFields(test.fields))
+		if test.expected == "" {
+			if err == nil {
+				t.Errorf("%q: got %q, expected an error", test.fields, got)
+			}
+			continue
+		}
+		if err != nil || got != test.expected {
+			t.Errorf("%q: got %q, %v, expected %q", test.fields, got, err, test.expected)
+		}
+	}
+}
+
+func TestLoadMaterialsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{"before newmtl", "Kd 1 1 1\n", `Error at 1: "Kd" before newmtl`},
+		{"color values", "newmtl a\nKd 1 1\n", "Error at 2: expected at least 3 values, got 2"},
+		{"number", "newmtl a\n\nNs x\n", `Error at 3: invalid number "x"`},
+		{"texture map", "newmtl a\nmap_Kd -bm 1\n", "Error at 2: missing texture file name"},
+	}
+
+	for _, test := range tests {
+		_, err := LoadMaterials(strings.NewReader(test.src))
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%v: got error %v, expected %q", test.name, err, test.err)
+		}
+	}
+}
+
+// splitFields splits s into fields like the scanner
+func splitFields(s string) [][]byte {
+	var fields [][]byte
+	for _, field := range strings.Fields(s) {
+		fields = append(fields, []byte(field))
+	}
+	return fields
+}
@@ -0,0 +1,239 @@
+package obj
+
+import (
+	"errors"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// NormalMode selects how GenerateNormals computes the normals.
+type NormalMode int
+
+const (
+	// FlatNormals uses the face normal for all corners of a face
+	FlatNormals NormalMode = iota
+	// AreaWeighted averages face normals weighted by face area
+	AreaWeighted
+	// AngleWeighted averages face normals weighted by the corner angle
+	AngleWeighted
+)
+
+// GenerateNormals replaces Normal with computed normals.
+//
+// With AreaWeighted and AngleWeighted the normals are averaged over
+// faces that share a vertex position and a smoothing group, faces
+// with smoothing group 0 get flat normals. When Smoothing is nil
+// all faces are smoothed together.
+//
+// Tangent and Bitangent are cleared, because they depend on the normals.
+func (data *Data) GenerateNormals(mode NormalMode) {
+	indexed := data.Indices != nil
+	data.expand()
+
+	data.Normal = make([]float32, len(data.Vertex))
+	data.fillNormals(mode, nil)
+
+	data.Tangent = nil
+	data.Bitangent = nil
+
+	if indexed {
+		*data = *data.Indexed()
+	}
+}
+
+// fillNormals computes the normals of the non-indexed elements for
+// which missing is true, as in GenerateNormals. Only the triangles
+// with a missing normal are averaged. A nil missing fills all normals.
+func (data *Data) fillNormals(mode NormalMode, missing []bool) {
+	type smoothKey struct {
+		position mgl32.Vec3
+		group    uint32
+	}
+
+	count := len(data.Vertex) / 3
+	affected := func(t int) bool {
+		return missing == nil || missing[t*3] || missing[t*3+1] || missing[t*3+2]
+	}
+
+	normals := make([]mgl32.Vec3, count)
+	sums := make(map[smoothKey]mgl32.Vec3)
+
+	for t := 0; t < count/3; t++ {
+		if !affected(t) {
+			continue
+		}
+		p := data.triangle(t)
+		face := p[1].Sub(p[0]).Cross(p[2].Sub(p[0]))
+
+		group := data.smoothingGroup(t, mode)
+		for k := range p {
+			if group == 0 {
+				normals[t*3+k] = face
+				continue
+			}
+
+			weight := face
+			if mode == AngleWeighted {
+				weight = normalize(face).Mul(cornerAngle(p, k))
+			}
+			key := smoothKey{p[k], group}
+			sums[key] = sums[key].Add(weight)
+		}
+	}
+
+	for e, normal := range normals {
+		if missing != nil && !missing[e] {
+			continue
+		}
+		if group := data.smoothingGroup(e/3, mode); group != 0 {
+			normal = sums[smoothKey{data.position(e), group}]
+		}
+		normal = normalize(normal)
+		copy(data.Normal[e*3:e*3+3], normal[:])
+	}
+}
+
+// GenerateTangents computes Tangent and Bitangent from UV and Normal.
+//
+// The tangent frames follow the MikkTSpace conventions: face tangents
+// are averaged with corner angle weights, orthogonalized against
+// the normal and the handedness is stored in the w component of
+// Tangent, such that Bitangent = w * cross(Normal, Tangent.xyz).
+func (data *Data) GenerateTangents() error {
+	if data.UV == nil {
+		return errors.New("Tangents require texture coordinates")
+	}
+	if data.Normal == nil {
+		return errors.New("Tangents require normals")
+	}
+
+	indexed := data.Indices != nil
+	data.expand()
+
+	type tangentKey struct {
+		position mgl32.Vec3
+		normal   mgl32.Vec3
+		uv       mgl32.Vec2
+	}
+	type frame struct{ tangent, bitangent mgl32.Vec3 }
+
+	count := len(data.Vertex) / 3
+	sums := make(map[tangentKey]frame)
+	key := func(e int) tangentKey {
+		return tangentKey{data.position(e), data.normal(e), data.uv(e)}
+	}
+
+	for t := 0; t < count/3; t++ {
+		p := data.triangle(t)
+		uv0, uv1, uv2 := data.uv(t*3), data.uv(t*3+1), data.uv(t*3+2)
+
+		e1, e2 := p[1].Sub(p[0]), p[2].Sub(p[0])
+		du1, dv1 := uv1[0]-uv0[0], uv1[1]-uv0[1]
+		du2, dv2 := uv2[0]-uv0[0], uv2[1]-uv0[1]
+
+		det := du1*dv2 - du2*dv1
+		if det == 0 {
+			continue
+		}
+		tangent := normalize(e1.Mul(dv2).Sub(e2.Mul(dv1)).Mul(1 / det))
+		bitangent := normalize(e2.Mul(du1).Sub(e1.Mul(du2)).Mul(1 / det))
+
+		for k := range p {
+			angle := cornerAngle(p, k)
+			sum := sums[key(t*3+k)]
+			sum.tangent = sum.tangent.Add(tangent.Mul(angle))
+			sum.bitangent = sum.bitangent.Add(bitangent.Mul(angle))
+			sums[key(t*3+k)] = sum
+		}
+	}
+
+	data.Tangent = make([]float32, 0, count*4)
+	data.Bitangent = make([]float32, 0, count*3)
+	for e := 0; e < count; e++ {
+		sum := sums[key(e)]
+		normal := normalize(data.normal(e))
+
+		tangent := normalize(sum.tangent.Sub(normal.Mul(normal.Dot(sum.tangent))))
+		if tangent.Len() == 0 {
+			tangent = perpendicular(normal)
+		}
+
+		handedness := float32(1)
+		if normal.Cross(tangent).Dot(sum.bitangent) < 0 {
+			handedness = -1
+		}
+		bitangent := normal.Cross(tangent).Mul(handedness)
+
+		data.Tangent = append(data.Tangent, tangent[0], tangent[1], tangent[2], handedness)
+		data.Bitangent = append(data.Bitangent, bitangent[:]...)
+	}
+
+	if indexed {
+		*data = *data.Indexed()
+	}
+	return nil
+}
+
+// smoothingGroup returns the smoothing group of triangle t for mode
+func (data *Data) smoothingGroup(t int, mode NormalMode) uint32 {
+	switch {
+	case mode == FlatNormals:
+		return 0
+	case data.Smoothing == nil:
+		return 1
+	}
+	return data.Smoothing[t]
+}
+
+// triangle returns the positions of non-indexed triangle t
+func (data *Data) triangle(t int) [3]mgl32.Vec3 {
+	return [3]mgl32.Vec3{
+		data.position(t * 3),
+		data.position(t*3 + 1),
+		data.position(t*3 + 2),
+	}
+}
+
+func (data *Data) position(i int) mgl32.Vec3 {
+	return mgl32.Vec3{data.Vertex[i*3], data.Vertex[i*3+1], data.Vertex[i*3+2]}
+}
+
+func (data *Data) normal(i int) mgl32.Vec3 {
+	return mgl32.Vec3{data.Normal[i*3], data.Normal[i*3+1], data.Normal[i*3+2]}
+}
+
+func (data *Data) uv(i int) mgl32.Vec2 {
+	return mgl32.Vec2{data.UV[i*2], data.UV[i*2+1]}
+}
+
+// cornerAngle returns the angle of the triangle p at corner k
+func cornerAngle(p [3]mgl32.Vec3, k int) float32 {
+	a := normalize(p[(k+1)%3].Sub(p[k]))
+	b := normalize(p[(k+2)%3].Sub(p[k]))
+	return float32(math.Acos(float64(mgl32.Clamp(a.Dot(b), -1, 1))))
+}
+
+// normalize returns v with unit length or zero vector
+func normalize(v mgl32.Vec3) mgl32.Vec3 {
+	if n := v.Len(); n > 0 {
+		return v.Mul(1 / n)
+	}
+	return mgl32.Vec3{}
+}
+
+// perpendicular returns an unit vector perpendicular to n
+func perpendicular(n mgl32.Vec3) mgl32.Vec3 {
+	axis := mgl32.Vec3{1, 0, 0}
+	if abs(n[0]) > 0.9 {
+		axis = mgl32.Vec3{0, 1, 0}
+	}
+	return normalize(axis.Sub(n.Mul(n.Dot(axis))))
+}
+
+func abs(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,72 @@
+package obj
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestLoadMixedNormals(t *testing.T) {
+	const src = `
+v 0 0 0
+v 1 0 0
+v 0 1 0
+vn 1 0 0
+o first
+f 1//1 2//1 3//1
+o second
+f 1 2 3
+`
+	for _, reverse := range []bool{false, true} {
+		data, err := LoadWithOptions(strings.NewReader(src), Options{ReverseWinding: reverse})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data.Objects) != 2 || data.Objects[1].Name != "second" {
+			t.Fatalf("unexpected objects %+v", data.Objects)
+		}
+
+		// the generated normal follows the winding
+		generated := mgl32.Vec3{0, 0, 1}
+		if reverse {
+			generated = mgl32.Vec3{0, 0, -1}
+		}
+
+		for e := 0; e < 6; e++ {
+			expected := mgl32.Vec3{1, 0, 0}
+			if e >= data.Objects[1].Start {
+				expected = generated
+			}
+			if got := data.normal(e); !got.ApproxEqual(expected) {
+				t.Errorf("reverse %v: element %d has normal %v, expected %v", reverse, e, got, expected)
+			}
+		}
+	}
+}
+
+func TestGenerateNormals(t *testing.T) {
+	// two triangles folded along the x axis
+	data := &Data{Vertex: []float32{
+		0, 0, 0, 1, 0, 0, 0, 1, 0,
+		1, 0, 0, 0, 0, 0, 0, 0, 1,
+	}}
+
+	flat := []mgl32.Vec3{{0, 0, 1}, {0, 0, 1}, {0, 0, 1}, {0, 1, 0}, {0, 1, 0}, {0, 1, 0}}
+	data.GenerateNormals(FlatNormals)
+	for e, expected := range flat {
+		if got := data.normal(e); !got.ApproxEqual(expected) {
+			t.Errorf("flat: element %d has normal %v, expected %v", e, got, expected)
+		}
+	}
+
+	// the shared edge is averaged, the other corners keep the face normal
+	shared := normalize(mgl32.Vec3{0, 1, 1})
+	smooth := []mgl32.Vec3{shared, shared, {0, 0, 1}, shared, shared, {0, 1, 0}}
+	data.GenerateNormals(AreaWeighted)
+	for e, expected := range smooth {
+		if got := data.normal(e); !got.ApproxEqual(expected) {
+			t.Errorf("smooth: element %d has normal %v, expected %v", e, got, expected)
+		}
+	}
+}
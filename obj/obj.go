@@ -2,91 +2,369 @@ package obj
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strconv"
 
 	"github.com/go-gl/mathgl/mgl32"
 )
 
+// Data contains triangles, three elements per triangle.
+//
+// When Indices is nil, the triangles are not indexed and each
+// element is a vertex, otherwise Indices refers to the vertices.
+// Use Indexed to merge identical vertices.
+//
+// UV is nil when none of the faces reference texture coordinates.
+// When only some of the faces reference texture coordinates, the
+// missing values are zero, missing normals are generated. Tangent
+// (with handedness in w) and Bitangent are nil until
+// GenerateTangents is called.
+//
+// Submeshes split the elements into consecutive ranges that use
+// the same material, they cover all the elements.
+//
+// Objects and Groups contain the ranges of named objects ("o") and
+// groups ("g"). Faces can belong to several groups, hence group
+// ranges may overlap. Smoothing contains the smoothing group for
+// each triangle, 0 means smoothing is off. Smoothing is nil when
+// the file does not specify smoothing groups.
 type Data struct {
-	Vertex []float32
-	UV     []float32
-	Normal []float32
+	Vertex    []float32
+	UV        []float32
+	Normal    []float32
+	Tangent   []float32
+	Bitangent []float32
+
+	Indices []uint32
+
+	Submeshes    []Submesh
+	MaterialLibs []string
+	Materials    map[string]*Material
+
+	Objects   []Group
+	Groups    []Group
+	Smoothing []uint32
 }
 
+// Submesh is a range of elements that use the same material.
+//
+// Material is empty when the faces do not have a material.
+type Submesh struct {
+	Material string
+	Start    int
+	Count    int
+}
+
+// LoadFile loads a model and the material libraries it references
+// using DefaultOptions.
+//
+// Material libraries are looked up relative to the model,
+// missing libraries are ignored.
 func LoadFile(filename string) (*Data, error) {
+	return LoadFileWithOptions(filename, DefaultOptions)
+}
+
+// LoadFileWithOptions loads a model and the material libraries
+// it references.
+func LoadFileWithOptions(filename string, opts Options) (*Data, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 
-	return Load(bufio.NewReader(file))
+	data, err := LoadWithOptions(bufio.NewReader(file), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(filename)
+	for _, lib := range data.MaterialLibs {
+		materials, err := LoadMaterialsFile(filepath.Join(dir, lib))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error loading %v: %v", lib, err)
+		}
+		for name, material := range materials {
+			data.Materials[name] = material
+		}
+	}
+
+	return data, nil
 }
 
+// index refers to a vertex, uv and normal of a face corner,
+// -1 means the value is missing
+type index struct{ v, uv, n int }
+
+// Load loads a model using DefaultOptions.
 func Load(r io.Reader) (*Data, error) {
-	var err error
+	return LoadWithOptions(r, DefaultOptions)
+}
+
+// LoadWithOptions loads a model, material libraries are listed
+// in MaterialLibs, but not loaded.
+//
+// Normals that the faces do not reference are generated: smooth
+// normals for faces in a smoothing group, flat normals otherwise.
+func LoadWithOptions(r io.Reader, opts Options) (*Data, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
 
 	data := &Data{}
+	data.Materials = make(map[string]*Material)
+	material := ""
+
+	var objects, groups activeGroups
+	smoothing, hasSmoothing := uint32(0), false
 
 	var vertices []mgl32.Vec3
 	var uvs []mgl32.Vec2
 	var normals []mgl32.Vec3
 
-	line := 0
-	for err == nil {
-		var hdr string
-		line += 1
+	hasUV, hasNormal := false, false
+	var face []index
+	// missingNormal is true for the elements without a normal
+	var missingNormal []bool
 
-		_, err = fmt.Fscanf(r, "%s", &hdr)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("Error at %d: %v", line, err)
-		}
+	scanner := newScanner(r)
+	for scanner.next() {
+		fields := scanner.fields
 
-		switch hdr {
+		var err error
+		switch string(fields[0]) {
 		case "v":
 			var v mgl32.Vec3
-			_, err = fmt.Fscanf(r, "%f %f %f\n", &v[0], &v[1], &v[2])
+			err = parseFloats(v[:], fields[1:], 3)
 			vertices = append(vertices, v)
 		case "vt":
 			var uv mgl32.Vec2
-			_, err = fmt.Fscanf(r, "%f %f\n", &uv[0], &uv[1])
-			uv[1] = -uv[1] // for DDS!!!
+			err = parseFloats(uv[:], fields[1:], 1)
 			uvs = append(uvs, uv)
 		case "vn":
 			var v mgl32.Vec3
-			_, err = fmt.Fscanf(r, "%f %f %f\n", &v[0], &v[1], &v[2])
+			err = parseFloats(v[:], fields[1:], 3)
 			normals = append(normals, v)
+		case "mtllib":
+			data.MaterialLibs = append(data.MaterialLibs, fieldStrings(fields[1:])...)
+		case "usemtl":
+			material = joinFields(fields[1:])
+		case "o":
+			// "o" without a name ends the object, like "g" without names
+			var names []string
+			if len(fields) > 1 {
+				names = []string{joinFields(fields[1:])}
+			}
+			objects.set(names)
+		case "g":
+			groups.set(fieldStrings(fields[1:]))
+		case "s":
+			hasSmoothing = true
+			smoothing, err = parseSmoothing(fields[1:])
 		case "f":
-			var vi, uvi, ni [3]int
-			_, err = fmt.Fscanf(r, "%d/%d/%d %d/%d/%d %d/%d/%d\n",
-				&vi[0], &uvi[0], &ni[0],
-				&vi[1], &uvi[1], &ni[1],
-				&vi[2], &uvi[2], &ni[2])
-
-			data.Vertex = append(data.Vertex, vertices[vi[0]-1][:]...)
-			data.Vertex = append(data.Vertex, vertices[vi[1]-1][:]...)
-			data.Vertex = append(data.Vertex, vertices[vi[2]-1][:]...)
-
-			data.UV = append(data.UV, uvs[uvi[0]-1][:]...)
-			data.UV = append(data.UV, uvs[uvi[1]-1][:]...)
-			data.UV = append(data.UV, uvs[uvi[2]-1][:]...)
-
-			data.Normal = append(data.Normal, normals[ni[0]-1][:]...)
-			data.Normal = append(data.Normal, normals[ni[1]-1][:]...)
-			data.Normal = append(data.Normal, normals[ni[2]-1][:]...)
-		default:
-			var s string
-			_, err = fmt.Fscanf(r, "%s\n", &s)
+			if len(fields) < 4 {
+				err = fmt.Errorf("face has %d vertices, expected at least 3", len(fields)-1)
+				break
+			}
+
+			face = face[:0]
+			for _, corner := range fields[1:] {
+				var idx index
+				idx, err = parseIndex(corner, len(vertices), len(uvs), len(normals))
+				if err != nil {
+					break
+				}
+				hasUV = hasUV || idx.uv >= 0
+				hasNormal = hasNormal || idx.n >= 0
+				face = append(face, idx)
+			}
+			if err != nil {
+				break
+			}
+
+			start := len(data.Vertex) / 3
+
+			// triangulate polygons as a fan around the first corner
+			for i := 1; i+1 < len(face); i++ {
+				data.Smoothing = append(data.Smoothing, smoothing)
+				for _, idx := range [3]index{face[0], face[i], face[i+1]} {
+					data.Vertex = append(data.Vertex, vertices[idx.v][:]...)
+
+					var uv mgl32.Vec2
+					if idx.uv >= 0 {
+						uv = uvs[idx.uv]
+					}
+					data.UV = append(data.UV, uv[:]...)
+
+					var n mgl32.Vec3
+					if idx.n >= 0 {
+						n = normals[idx.n]
+					}
+					data.Normal = append(data.Normal, n[:]...)
+					missingNormal = append(missingNormal, idx.n < 0)
+				}
+			}
+
+			count := 3 * (len(face) - 2)
+			data.extendSubmesh(material, count)
+			data.Objects = objects.extend(data.Objects, start, count)
+			data.Groups = groups.extend(data.Groups, start, count)
 		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Error at %d: %v", scanner.line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Error at %d: %v", scanner.line, err)
+	}
+
+	if !hasUV {
+		data.UV = nil
+	}
+	if !hasSmoothing {
+		data.Smoothing = nil
+	}
+	if !hasNormal {
+		data.Normal = nil
+	}
+
+	opts.apply(data)
+
+	mode := FlatNormals
+	if data.Smoothing != nil {
+		mode = AngleWeighted
 	}
-	if err != nil && err != io.EOF {
-		return nil, fmt.Errorf("Error at %d: %v", line, err)
+	if data.Normal == nil {
+		data.GenerateNormals(mode)
+	} else {
+		// the flags follow the corners when the winding is reversed
+		if opts.ReverseWinding {
+			for t := 0; t+2 < len(missingNormal); t += 3 {
+				missingNormal[t+1], missingNormal[t+2] = missingNormal[t+2], missingNormal[t+1]
+			}
+		}
+		data.fillNormals(mode, missingNormal)
 	}
 
 	return data, nil
 }
+
+// extendSubmesh adds count elements to the last submesh
+// or starts a new one when the material changes
+func (data *Data) extendSubmesh(material string, count int) {
+	if n := len(data.Submeshes); n > 0 && data.Submeshes[n-1].Material == material {
+		data.Submeshes[n-1].Count += count
+		return
+	}
+	start := 0
+	if n := len(data.Submeshes); n > 0 {
+		last := data.Submeshes[n-1]
+		start = last.Start + last.Count
+	}
+	data.Submeshes = append(data.Submeshes, Submesh{
+		Material: material,
+		Start:    start,
+		Count:    count,
+	})
+}
+
+// parseSmoothing parses the smoothing group of "s" statement
+func parseSmoothing(fields [][]byte) (uint32, error) {
+	if len(fields) != 1 {
+		return 0, fmt.Errorf("expected smoothing group")
+	}
+	if string(fields[0]) == "off" {
+		return 0, nil
+	}
+	group, err := strconv.ParseUint(string(fields[0]), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid smoothing group %q", fields[0])
+	}
+	return uint32(group), nil
+}
+
+// parseFloats parses fields into dst, fields beyond len(dst) are ignored
+// and dst values without a field keep their value
+func parseFloats(dst []float32, fields [][]byte, required int) error {
+	if len(fields) < required {
+		return fmt.Errorf("expected at least %d values, got %d", required, len(fields))
+	}
+	for i := range dst {
+		if i >= len(fields) {
+			break
+		}
+		v, err := parseFloat(fields[i])
+		if err != nil {
+			return fmt.Errorf("invalid number %q", fields[i])
+		}
+		dst[i] = v
+	}
+	return nil
+}
+
+// parseIndex parses a face corner in one of the forms
+// "v", "v/vt", "v//vn" or "v/vt/vn"
+func parseIndex(s []byte, nv, nuv, nn int) (idx index, err error) {
+	var parts [3][]byte
+	rest := s
+	for i := range parts {
+		k := bytes.IndexByte(rest, '/')
+		if k < 0 {
+			parts[i], rest = rest, nil
+			break
+		}
+		parts[i], rest = rest[:k], rest[k+1:]
+		if i == len(parts)-1 {
+			return idx, fmt.Errorf("invalid face vertex %q", s)
+		}
+	}
+
+	idx = index{-1, -1, -1}
+	idx.v, err = resolveIndex(parts[0], nv)
+	if err != nil {
+		return idx, fmt.Errorf("invalid vertex index in %q: %v", s, err)
+	}
+	if len(parts[1]) > 0 {
+		idx.uv, err = resolveIndex(parts[1], nuv)
+		if err != nil {
+			return idx, fmt.Errorf("invalid uv index in %q: %v", s, err)
+		}
+	}
+	if len(parts[2]) > 0 {
+		idx.n, err = resolveIndex(parts[2], nn)
+		if err != nil {
+			return idx, fmt.Errorf("invalid normal index in %q: %v", s, err)
+		}
+	}
+	return idx, nil
+}
+
+var errMissingIndex = errors.New("missing index")
+
+// resolveIndex converts a 1-based or negative (relative) index
+// into a 0-based index into a list of n elements
+func resolveIndex(s []byte, n int) (int, error) {
+	if len(s) == 0 {
+		return 0, errMissingIndex
+	}
+	i, err := parseInt(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	switch {
+	case i > 0 && i <= n:
+		return i - 1, nil
+	case i < 0 && -i <= n:
+		return n + i, nil
+	}
+	return 0, fmt.Errorf("%d is out of range, %d defined", i, n)
+}
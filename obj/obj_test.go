@@ -0,0 +1,304 @@
+package obj
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	type statement struct {
+		line   int
+		fields string
+	}
+
+	tests := []struct {
+		name     string
+		src      string
+		expected []statement
+	}{
+		{"empty", "", nil},
+		{"fields", "v 1  2\t3\nf 1 2 3", []statement{{1, "v|1|2|3"}, {2, "f|1|2|3"}}},
+		{"comments", "# header\nv 1 2 3 # trailing\n   # indented\nvn 0 0 1\n", []statement{{2, "v|1|2|3"}, {4, "vn|0|0|1"}}},
+		{"continuation", "f 1 2 \\\n  3 4\nv 1 2 3\n", []statement{{1, "f|1|2|3|4"}, {3, "v|1|2|3"}}},
+		{"continued comment", "v 1 2 3 # a \\\nf 1 2 3\n", []statement{{1, "v|1|2|3"}}},
+		{"crlf", "v 1 2 3\r\nf 1 \\\r\n2 3\r\n\r\nvt 0 1\r\n", []statement{{1, "v|1|2|3"}, {2, "f|1|2|3"}, {5, "vt|0|1"}}},
+		{"empty lines", "\n\n\t\nv 1 2 3", []statement{{4, "v|1|2|3"}}},
+	}
+
+	for _, test := range tests {
+		var got []statement
+		s := newScanner(strings.NewReader(test.src))
+		for s.next() {
+			got = append(got, statement{s.line, string(bytes.Join(s.fields, []byte("|")))})
+		}
+		if err := s.Err(); err != nil {
+			t.Errorf("%v: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%v: got %v, expected %v", test.name, got, test.expected)
+		}
+	}
+}
+
+func TestScannerLongLine(t *testing.T) {
+	// longer than the buffer of the reader
+	long := "g " + strings.Repeat("x", 100<<10)
+	s := newScanner(strings.NewReader(long + "\nv 1 2 3\n"))
+	if !s.next() || len(s.fields) != 2 || len(s.fields[1]) != 100<<10 {
+		t.Fatalf("long line not read")
+	}
+	if !s.next() || s.line != 2 || string(s.fields[0]) != "v" {
+		t.Fatalf("line after long line not read")
+	}
+}
+
+func TestLoadCRLF(t *testing.T) {
+	const src = "v 0 0 0\nv 1 0 0\nv 1 1 0\nv 0 1 0\nvt 0 0\nvn 0 0 1\n" +
+		"# quad\nf 1/1/1 2/1/1 \\\n 3/1/1 4/1/1\nusemtl red\nf -4/1/1 -3/1/1 -2/1/1\n"
+
+	lf, err := Load(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	crlf, err := Load(strings.NewReader(strings.Replace(src, "\n", "\r\n", -1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lf, crlf) {
+		t.Errorf("CRLF file differs:\n%+v\n%+v", lf, crlf)
+	}
+	if len(lf.Vertex) != 9*3 || len(lf.Submeshes) != 2 || lf.Submeshes[1].Material != "red" {
+		t.Errorf("unexpected data %+v", lf)
+	}
+}
+
+func TestLoadErrorLine(t *testing.T) {
+	_, err := Load(strings.NewReader("v 0 0 0\n# comment\nf 1 \\\n2 3\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "Error at 3:") {
+		t.Errorf("got %v, expected error at line 3", err)
+	}
+}
+
+func TestLoadFaces(t *testing.T) {
+	const triangle = "v 0 0 0\nv 1 0 0\nv 0 1 0\nvt 0 0\nvt 1 0\nvt 0 1\nvn 0 0 -1\n"
+	vertex := []float32{0, 0, 0, 1, 0, 0, 0, 1, 0}
+	uv := []float32{0, 0, 1, 0, 0, 1}
+	// flat normals follow the counter-clockwise winding
+	generated := []float32{0, 0, 1, 0, 0, 1, 0, 0, 1}
+	loaded := []float32{0, 0, -1, 0, 0, -1, 0, 0, -1}
+
+	tests := []struct {
+		name   string
+		src    string
+		vertex []float32
+		uv     []float32
+		normal []float32
+	}{
+		{"v", triangle + "f 1 2 3", vertex, nil, generated},
+		{"v/vt", triangle + "f 1/1 2/2 3/3", vertex, uv, generated},
+		{"v//vn", triangle + "f 1//1 2//1 3//1", vertex, nil, loaded},
+		{"v/vt/vn", triangle + "f 1/1/1 2/2/1 3/3/1", vertex, uv, loaded},
+		{"negative", triangle + "f -3/-3/-1 -2/-2/-1 -1/-1/-1\nv 5 5 5", vertex, uv, loaded},
+		{"mixed", triangle + "f 3/3 1/1 2/2\nf 1 2 3",
+			[]float32{0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 1, 0},
+			[]float32{0, 1, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0},
+			append(append([]float32{}, generated...), generated...)},
+		{"quad", triangle + "v 1 1 0\nf 1 2 4 3",
+			[]float32{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 0, 0, 1, 1, 0, 0, 1, 0},
+			nil,
+			append(append([]float32{}, generated...), generated...)},
+	}
+
+	for _, test := range tests {
+		data, err := LoadWithOptions(strings.NewReader(test.src), Options{})
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(data.Vertex, test.vertex) {
+			t.Errorf("%v: got vertices %v, expected %v", test.name, data.Vertex, test.vertex)
+		}
+		if !reflect.DeepEqual(data.UV, test.uv) {
+			t.Errorf("%v: got uvs %v, expected %v", test.name, data.UV, test.uv)
+		}
+		if !reflect.DeepEqual(data.Normal, test.normal) {
+			t.Errorf("%v: got normals %v, expected %v", test.name, data.Normal, test.normal)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	const triangle = "v 0 0 0\nv 1 0 0\n# comment\nv 0 1 0\n"
+
+	tests := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{"face size", triangle + "f 1 2", `Error at 5: face has 2 vertices, expected at least 3`},
+		{"vertex range", triangle + "f 1 2 4", `Error at 5: invalid vertex index in "4": 4 is out of range, 3 defined`},
+		{"zero index", triangle + "f 0 1 2", `Error at 5: invalid vertex index in "0": 0 is out of range, 3 defined`},
+		{"negative range", triangle + "f 1 2 -4", `Error at 5: invalid vertex index in "-4": -4 is out of range, 3 defined`},
+		{"missing vertex", triangle + "f /1 2 3", `Error at 5: invalid vertex index in "/1": missing index`},
+		{"uv range", triangle + "vt 0 0\nf 1/1 2/2 3/1", `Error at 6: invalid uv index in "2/2": 2 is out of range, 1 defined`},
+		{"normal", triangle + "f 1//x 2 3", `Error at 5: invalid normal index in "1//x": "x" is not a number`},
+		{"face vertex", triangle + "f 1/1/1/1 2 3", `Error at 5: invalid face vertex "1/1/1/1"`},
+		{"continued face", triangle + "f 1 \\\n 2 9", `Error at 5: invalid vertex index in "9": 9 is out of range, 3 defined`},
+		{"vertex values", "v 1 2", `Error at 1: expected at least 3 values, got 2`},
+		{"vertex number", triangle + "v 1 a 2", `Error at 5: invalid number "a"`},
+		{"smoothing", triangle + "s x", `Error at 5: invalid smoothing group "x"`},
+	}
+
+	for _, test := range tests {
+		_, err := Load(strings.NewReader(test.src))
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%v: got error %v, expected %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestParseFloat(t *testing.T) {
+	inputs := []string{
+		"0", "-0", "+1", "1.", ".5", "-.5", "0.1", "3.14159", "-2.5e3",
+		"1E-7", "1e+10", "123456789012345", "1234567890123456789",
+		"0.000000000000000000000000000000000000000001", "1e38", "1e39",
+		"3.4028235e38", "1.17549435e-38", "1.4e-45", "16777217", "0.30000001192092896",
+		"1e", "1e+", "e5", ".", "-", "", "1.2.3", "0x10", "nan", "inf", "1,5",
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		v := (rng.Float64() - 0.5) * float64(rng.Intn(1e6))
+		inputs = append(inputs,
+			strconv.FormatFloat(v, 'f', rng.Intn(10), 64),
+			strconv.FormatFloat(v, 'e', rng.Intn(16), 64))
+	}
+
+	// numbers close to the midpoint of two float32 values,
+	// where rounding through float64 can go the wrong way
+	for i := 0; i < 10000; i++ {
+		f := math.Float32frombits(rng.Uint32() & 0x7F7FFFFF)
+		mid := (float64(f) + float64(math.Nextafter32(f, float32(math.Inf(1))))) / 2
+		inputs = append(inputs, strconv.FormatFloat(mid, 'g', 12+rng.Intn(4), 64))
+	}
+
+	for _, input := range inputs {
+		want, wantErr := strconv.ParseFloat(input, 32)
+		got, err := parseFloat([]byte(input))
+		if (err != nil) != (wantErr != nil) {
+			t.Errorf("%q: got error %v, expected %v", input, err, wantErr)
+			continue
+		}
+		if err == nil && got != float32(want) && !(got != got && want != want) {
+			t.Errorf("%q: got %v, expected %v", input, got, float32(want))
+		}
+	}
+}
+
+func TestParseInt(t *testing.T) {
+	for _, input := range []string{"0", "1", "-1", "+7", "123456789", "-1234567890", "12345678901234", "", "-", "1a", "1.0"} {
+		want, wantErr := strconv.Atoi(input)
+		got, err := parseInt([]byte(input))
+		if (err != nil) != (wantErr != nil) || got != want {
+			t.Errorf("%q: got %v, %v, expected %v, %v", input, got, err, want, wantErr)
+		}
+	}
+}
+
+// generateMesh returns a grid of size x size quads as triangles
+// with positions, texture coordinates and normals
+func generateMesh(size int) []byte {
+	var b bytes.Buffer
+	rng := rand.New(rand.NewSource(1))
+	for y := 0; y <= size; y++ {
+		for x := 0; x <= size; x++ {
+			fmt.Fprintf(&b, "v %.6f %.6f %.6f\n", float64(x)/float64(size), rng.Float64()*0.1, float64(y)/float64(size))
+			fmt.Fprintf(&b, "vt %.6f %.6f\n", float64(x)/float64(size), float64(y)/float64(size))
+			fmt.Fprintf(&b, "vn %.6f %.6f %.6f\n", rng.Float64()*0.1, 1.0, rng.Float64()*0.1)
+		}
+	}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			a := y*(size+1) + x + 1
+			c := a + size + 1
+			fmt.Fprintf(&b, "f %d/%d/%d %d/%d/%d %d/%d/%d\n", a, a, a, a+1, a+1, a+1, c+1, c+1, c+1)
+			fmt.Fprintf(&b, "f %d/%d/%d %d/%d/%d %d/%d/%d\n", a, a, a, c+1, c+1, c+1, c, c, c)
+		}
+	}
+	return b.Bytes()
+}
+
+func BenchmarkLoad(b *testing.B) {
+	mesh := generateMesh(250)
+	b.SetBytes(int64(len(mesh)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Load(bytes.NewReader(mesh)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkFscanf reads the same mesh with the fmt.Fscanf
+// tokenizer the loader used before, for comparison
+func BenchmarkFscanf(b *testing.B) {
+	mesh := generateMesh(250)
+	b.SetBytes(int64(len(mesh)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := loadFscanf(bytes.NewReader(mesh)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func loadFscanf(r io.Reader) error {
+	var vertices, normals [][3]float32
+	var uvs [][2]float32
+	var vertex, uv, normal []float32
+	for {
+		var hdr string
+		if _, err := fmt.Fscanf(r, "%s", &hdr); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var err error
+		switch hdr {
+		case "v":
+			var v [3]float32
+			_, err = fmt.Fscanf(r, "%f %f %f\n", &v[0], &v[1], &v[2])
+			vertices = append(vertices, v)
+		case "vt":
+			var v [2]float32
+			_, err = fmt.Fscanf(r, "%f %f\n", &v[0], &v[1])
+			uvs = append(uvs, v)
+		case "vn":
+			var v [3]float32
+			_, err = fmt.Fscanf(r, "%f %f %f\n", &v[0], &v[1], &v[2])
+			normals = append(normals, v)
+		case "f":
+			var vi, uvi, ni [3]int
+			_, err = fmt.Fscanf(r, "%d/%d/%d %d/%d/%d %d/%d/%d\n",
+				&vi[0], &uvi[0], &ni[0],
+				&vi[1], &uvi[1], &ni[1],
+				&vi[2], &uvi[2], &ni[2])
+			for k := range vi {
+				vertex = append(vertex, vertices[vi[k]-1][:]...)
+				uv = append(uv, uvs[uvi[k]-1][:]...)
+				normal = append(normal, normals[ni[k]-1][:]...)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package obj
+
+import "fmt"
+
+// FlipV selects how the V texture coordinate is transformed on load.
+type FlipV int
+
+const (
+	// KeepV keeps V as is
+	KeepV FlipV = iota
+	// NegateV uses -V, for DDS textures which are stored upside down
+	NegateV
+	// InvertV uses 1-V, for textures uploaded with the first row at the top
+	InvertV
+)
+
+// Options control how models are transformed while loading.
+//
+// The zero value loads the model as is.
+type Options struct {
+	// FlipV transforms the V texture coordinate
+	FlipV FlipV
+	// ZUp converts from Z-up to Y-up coordinates,
+	// (x, y, z) becomes (x, z, -y)
+	ZUp bool
+	// Scale uniformly scales the positions, must be positive,
+	// 0 means no scaling
+	Scale float32
+	// ReverseWinding reverses the winding order of the faces
+	ReverseWinding bool
+}
+
+// DefaultOptions are used by Load, LoadFile, Write and WriteFile.
+//
+// They negate V, to match the DDS textures used in the tutorials.
+var DefaultOptions = Options{FlipV: NegateV}
+
+// validate checks that the options are supported
+func (opts Options) validate() error {
+	if !(opts.Scale >= 0) {
+		return fmt.Errorf("Invalid scale %v, expected a positive value", opts.Scale)
+	}
+	return nil
+}
+
+// apply transforms data in place
+func (opts Options) apply(data *Data) {
+	for i := 1; i < len(data.UV); i += 2 {
+		data.UV[i] = opts.FlipV.apply(data.UV[i])
+	}
+
+	scale := opts.scale()
+	for i := 0; i+2 < len(data.Vertex); i += 3 {
+		v := data.Vertex[i : i+3]
+		if opts.ZUp {
+			v[1], v[2] = v[2], -v[1]
+		}
+		v[0], v[1], v[2] = v[0]*scale, v[1]*scale, v[2]*scale
+	}
+	if opts.ZUp {
+		for i := 0; i+2 < len(data.Normal); i += 3 {
+			n := data.Normal[i : i+3]
+			n[1], n[2] = n[2], -n[1]
+		}
+	}
+
+	if opts.ReverseWinding {
+		data.reverseWinding()
+	}
+}
+
+// unapply returns a copy of data with the inverse transformation
+// applied, tangents are not included in the copy
+func (opts Options) unapply(data *Data) *Data {
+	result := data.withoutVertices()
+	result.Vertex = append([]float32(nil), data.Vertex...)
+	if data.UV != nil {
+		result.UV = append([]float32(nil), data.UV...)
+	}
+	if data.Normal != nil {
+		result.Normal = append([]float32(nil), data.Normal...)
+	}
+	if data.Indices != nil {
+		result.Indices = append([]uint32(nil), data.Indices...)
+	}
+
+	for i := 1; i < len(result.UV); i += 2 {
+		result.UV[i] = opts.FlipV.apply(result.UV[i])
+	}
+
+	scale := 1 / opts.scale()
+	for i := 0; i+2 < len(result.Vertex); i += 3 {
+		v := result.Vertex[i : i+3]
+		v[0], v[1], v[2] = v[0]*scale, v[1]*scale, v[2]*scale
+		if opts.ZUp {
+			v[1], v[2] = -v[2], v[1]
+		}
+	}
+	if opts.ZUp {
+		for i := 0; i+2 < len(result.Normal); i += 3 {
+			n := result.Normal[i : i+3]
+			n[1], n[2] = -n[2], n[1]
+		}
+	}
+
+	if opts.ReverseWinding {
+		result.reverseWinding()
+	}
+	return result
+}
+
+func (opts Options) scale() float32 {
+	if opts.Scale == 0 {
+		return 1
+	}
+	return opts.Scale
+}
+
+// apply transforms v, all the transformations are their own inverse
+func (flip FlipV) apply(v float32) float32 {
+	switch flip {
+	case NegateV:
+		return -v
+	case InvertV:
+		return 1 - v
+	}
+	return v
+}
+
+// reverseWinding swaps the last two corners of each triangle
+func (data *Data) reverseWinding() {
+	if data.Indices != nil {
+		for t := 0; t+2 < len(data.Indices); t += 3 {
+			data.Indices[t+1], data.Indices[t+2] = data.Indices[t+2], data.Indices[t+1]
+		}
+		return
+	}
+
+	for _, attr := range data.attributes() {
+		values := *attr.values
+		for t := 0; t < len(values)/attr.size; t += 3 {
+			a, b := attr.vertex(t+1), attr.vertex(t+2)
+			for k := range a {
+				a[k], b[k] = b[k], a[k]
+			}
+		}
+	}
+}
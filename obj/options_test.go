@@ -0,0 +1,69 @@
+package obj
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadOptions(t *testing.T) {
+	const src = "v 1 2 3\nv 4 5 6\nv 7 8 10\nvt 0.25 0.25\nvn 0 0 1\nf 1/1/1 2/1/1 3/1/1\n"
+
+	tests := []struct {
+		name   string
+		opts   Options
+		vertex []float32
+		uv     []float32
+		normal []float32
+	}{
+		{"none", Options{}, []float32{1, 2, 3, 4, 5, 6, 7, 8, 10}, []float32{0.25, 0.25, 0.25, 0.25, 0.25, 0.25}, []float32{0, 0, 1, 0, 0, 1, 0, 0, 1}},
+		{"NegateV", Options{FlipV: NegateV}, []float32{1, 2, 3, 4, 5, 6, 7, 8, 10}, []float32{0.25, -0.25, 0.25, -0.25, 0.25, -0.25}, []float32{0, 0, 1, 0, 0, 1, 0, 0, 1}},
+		{"InvertV", Options{FlipV: InvertV}, []float32{1, 2, 3, 4, 5, 6, 7, 8, 10}, []float32{0.25, 0.75, 0.25, 0.75, 0.25, 0.75}, []float32{0, 0, 1, 0, 0, 1, 0, 0, 1}},
+		{"ZUp", Options{ZUp: true}, []float32{1, 3, -2, 4, 6, -5, 7, 10, -8}, []float32{0.25, 0.25, 0.25, 0.25, 0.25, 0.25}, []float32{0, 1, 0, 0, 1, 0, 0, 1, 0}},
+		{"Scale", Options{Scale: 0.5}, []float32{0.5, 1, 1.5, 2, 2.5, 3, 3.5, 4, 5}, []float32{0.25, 0.25, 0.25, 0.25, 0.25, 0.25}, []float32{0, 0, 1, 0, 0, 1, 0, 0, 1}},
+		{"ZUp and Scale", Options{ZUp: true, Scale: 2}, []float32{2, 6, -4, 8, 12, -10, 14, 20, -16}, []float32{0.25, 0.25, 0.25, 0.25, 0.25, 0.25}, []float32{0, 1, 0, 0, 1, 0, 0, 1, 0}},
+		{"ReverseWinding", Options{ReverseWinding: true}, []float32{1, 2, 3, 7, 8, 10, 4, 5, 6}, []float32{0.25, 0.25, 0.25, 0.25, 0.25, 0.25}, []float32{0, 0, 1, 0, 0, 1, 0, 0, 1}},
+	}
+
+	for _, test := range tests {
+		data, err := LoadWithOptions(strings.NewReader(src), test.opts)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(data.Vertex, test.vertex) {
+			t.Errorf("%v: got vertices %v, expected %v", test.name, data.Vertex, test.vertex)
+		}
+		if !reflect.DeepEqual(data.UV, test.uv) {
+			t.Errorf("%v: got uvs %v, expected %v", test.name, data.UV, test.uv)
+		}
+		if !reflect.DeepEqual(data.Normal, test.normal) {
+			t.Errorf("%v: got normals %v, expected %v", test.name, data.Normal, test.normal)
+		}
+	}
+}
+
+func TestReverseWindingNormals(t *testing.T) {
+	// generated normals follow the reversed winding
+	const src = "v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 3\n"
+	data, err := LoadWithOptions(strings.NewReader(src), Options{ReverseWinding: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []float32{0, 0, -1, 0, 0, -1, 0, 0, -1}; !reflect.DeepEqual(data.Normal, expected) {
+		t.Errorf("got normals %v, expected %v", data.Normal, expected)
+	}
+}
+
+func TestInvalidScale(t *testing.T) {
+	const src = "v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 3\n"
+	for _, scale := range []float32{-1, float32(math.NaN())} {
+		if _, err := LoadWithOptions(strings.NewReader(src), Options{Scale: scale}); err == nil || !strings.HasPrefix(err.Error(), "Invalid scale") {
+			t.Errorf("load with scale %v: got %v, expected invalid scale", scale, err)
+		}
+		if err := WriteWithOptions(&strings.Builder{}, &Data{}, Options{Scale: scale}); err == nil {
+			t.Errorf("write with scale %v did not fail", scale)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package obj
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"strconv"
+)
+
+// scanner splits OBJ and MTL files into fields.
+//
+// It handles "#" comments, "\" line continuations and CRLF line endings.
+type scanner struct {
+	r *bufio.Reader
+
+	line     int // line where the current statement starts
+	nextLine int // line that is read next
+
+	buf    []byte
+	fields [][]byte
+	err    error
+}
+
+func newScanner(r io.Reader) *scanner {
+	return &scanner{
+		r:        bufio.NewReaderSize(r, 64<<10),
+		nextLine: 1,
+	}
+}
+
+// next reads the next statement, skipping empty lines and comments
+func (s *scanner) next() bool {
+	for s.err == nil {
+		s.line = s.nextLine
+		s.buf = s.buf[:0]
+
+		for {
+			continued := s.readLine()
+			if !continued || s.err != nil {
+				break
+			}
+		}
+		if s.Err() != nil {
+			return false
+		}
+
+		s.split()
+		if len(s.fields) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// readLine appends a physical line to s.buf, continued is true
+// when the line ends with "\" and continues on the next line
+func (s *scanner) readLine() (continued bool) {
+	for {
+		chunk, err := s.r.ReadSlice('\n')
+		s.buf = append(s.buf, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			s.err = err
+		}
+		break
+	}
+	s.nextLine++
+
+	s.buf = bytes.TrimRight(s.buf, "\r\n")
+	if n := len(s.buf); n > 0 && s.buf[n-1] == '\\' {
+		s.buf[n-1] = ' '
+		return true
+	}
+	return false
+}
+
+// split splits s.buf into fields, ignoring comments
+func (s *scanner) split() {
+	s.fields = s.fields[:0]
+
+	buf := s.buf
+	if i := bytes.IndexByte(buf, '#'); i >= 0 {
+		buf = buf[:i]
+	}
+
+	start := -1
+	for i, c := range buf {
+		if isSpace(c) {
+			if start >= 0 {
+				s.fields = append(s.fields, buf[start:i])
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		s.fields = append(s.fields, buf[start:])
+	}
+}
+
+// Err returns the first non-EOF error
+func (s *scanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\v' || c == '\f'
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// joinFields joins fields with a single space
+func joinFields(fields [][]byte) string {
+	return string(bytes.Join(fields, []byte{' '}))
+}
+
+// fieldStrings converts fields to strings
+func fieldStrings(fields [][]byte) []string {
+	strs := make([]string, len(fields))
+	for i, field := range fields {
+		strs[i] = string(field)
+	}
+	return strs
+}
+
+var pow10 = [...]float64{
+	1e0, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9, 1e10,
+	1e11, 1e12, 1e13, 1e14, 1e15, 1e16, 1e17, 1e18, 1e19, 1e20,
+	1e21, 1e22,
+}
+
+// parseFloat parses a decimal number, numbers that cannot be
+// converted exactly with float64 arithmetic or that are halfway
+// between two float32 values use strconv
+func parseFloat(b []byte) (float32, error) {
+	i := 0
+	negative := false
+	if i < len(b) && (b[i] == '+' || b[i] == '-') {
+		negative = b[i] == '-'
+		i++
+	}
+
+	var mantissa uint64
+	digits, exp := 0, 0
+	hasDigits := false
+
+	for ; i < len(b) && isDigit(b[i]); i++ {
+		hasDigits = true
+		mantissa = mantissa*10 + uint64(b[i]-'0')
+		if mantissa > 0 {
+			digits++
+		}
+	}
+	if i < len(b) && b[i] == '.' {
+		i++
+		for ; i < len(b) && isDigit(b[i]); i++ {
+			hasDigits = true
+			mantissa = mantissa*10 + uint64(b[i]-'0')
+			if mantissa > 0 {
+				digits++
+			}
+			exp--
+		}
+	}
+	if hasDigits && i < len(b) && (b[i] == 'e' || b[i] == 'E') {
+		i++
+		expNegative := false
+		if i < len(b) && (b[i] == '+' || b[i] == '-') {
+			expNegative = b[i] == '-'
+			i++
+		}
+		e, hasExp := 0, false
+		for ; i < len(b) && isDigit(b[i]) && e < 1000; i++ {
+			hasExp = true
+			e = e*10 + int(b[i]-'0')
+		}
+		if !hasExp {
+			hasDigits = false
+		}
+		if expNegative {
+			e = -e
+		}
+		exp += e
+	}
+
+	if !hasDigits || i != len(b) || digits > 15 || exp < -len(pow10)+1 || exp > len(pow10)-1 {
+		v, err := strconv.ParseFloat(string(b), 32)
+		return float32(v), err
+	}
+
+	v := float64(mantissa)
+	if exp < 0 {
+		v /= pow10[-exp]
+	} else {
+		v *= pow10[exp]
+	}
+
+	// converting to float32 rounds a second time, which is wrong
+	// when v was rounded onto the midpoint of two float32 values
+	f := float32(v)
+	if float64(f) != v {
+		toward := float32(math.Inf(1))
+		if v < float64(f) {
+			toward = float32(math.Inf(-1))
+		}
+		if v == (float64(f)+float64(math.Nextafter32(f, toward)))/2 {
+			v, err := strconv.ParseFloat(string(b), 32)
+			return float32(v), err
+		}
+	}
+
+	if negative {
+		f = -f
+	}
+	return f, nil
+}
+
+// parseInt parses a decimal integer
+func parseInt(b []byte) (int, error) {
+	i := 0
+	negative := false
+	if i < len(b) && (b[i] == '+' || b[i] == '-') {
+		negative = b[i] == '-'
+		i++
+	}
+	if i == len(b) || len(b)-i > 9 {
+		return strconv.Atoi(string(b))
+	}
+
+	v := 0
+	for ; i < len(b); i++ {
+		if !isDigit(b[i]) {
+			return strconv.Atoi(string(b))
+		}
+		v = v*10 + int(b[i]-'0')
+	}
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
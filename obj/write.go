@@ -0,0 +1,284 @@
+package obj
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteFile writes data to filename and the materials to
+// a .mtl file with the same name next to it using DefaultOptions.
+//
+// When data has no materials, MaterialLibs is written as is.
+func WriteFile(filename string, data *Data) error {
+	return WriteFileWithOptions(filename, data, DefaultOptions)
+}
+
+// WriteFileWithOptions writes data to filename and the materials
+// to a .mtl file with the same name next to it.
+func WriteFileWithOptions(filename string, data *Data, opts Options) error {
+	if len(data.Materials) > 0 {
+		lib := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)) + ".mtl"
+		if err := writeFile(filepath.Join(filepath.Dir(filename), lib), func(w io.Writer) error {
+			return WriteMaterials(w, data.Materials)
+		}); err != nil {
+			return err
+		}
+
+		withLib := *data
+		withLib.MaterialLibs = []string{lib}
+		data = &withLib
+	}
+
+	return writeFile(filename, func(w io.Writer) error {
+		return WriteWithOptions(w, data, opts)
+	})
+}
+
+func writeFile(filename string, write func(w io.Writer) error) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Write writes data in Wavefront OBJ format using DefaultOptions.
+func Write(w io.Writer, data *Data) error {
+	return WriteWithOptions(w, data, DefaultOptions)
+}
+
+// WriteWithOptions writes data in Wavefront OBJ format, undoing the
+// transformations of opts, such that loading the result with the
+// same options gives back data.
+//
+// Materials are referenced by MaterialLibs and the submesh materials,
+// use WriteMaterials to write the material library itself.
+// Tangents are not written, since OBJ cannot store them.
+func WriteWithOptions(w io.Writer, data *Data, opts Options) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	data = opts.unapply(data)
+	if data.Indices == nil {
+		data = data.Indexed()
+	}
+
+	out := &writer{w: bufio.NewWriter(w)}
+
+	out.line("# vertices", len(data.Vertex)/3, "triangles", len(data.Indices)/3)
+	if len(data.MaterialLibs) > 0 {
+		out.line("mtllib", strings.Join(data.MaterialLibs, " "))
+	}
+
+	out.floats("v", data.Vertex, 3)
+	out.floats("vt", data.UV, 2)
+	out.floats("vn", data.Normal, 3)
+
+	boundaries := data.boundaries()
+
+	object, groups, material := "", []string(nil), ""
+	smoothing := uint32(0)
+
+	for e := 0; e < len(data.Indices); e += 3 {
+		if len(boundaries) > 0 && boundaries[0] == e {
+			boundaries = boundaries[1:]
+
+			// faces outside of objects need a bare "o" to end
+			// the previous object
+			name := ""
+			if names := activeNames(data.Objects, e); len(names) > 0 {
+				name = names[0]
+			}
+			if name != object {
+				object = name
+				if object == "" {
+					out.line("o")
+				} else {
+					out.line("o", object)
+				}
+			}
+			if names := activeNames(data.Groups, e); !equalNames(names, groups) {
+				groups = names
+				out.line("g", strings.Join(groups, " "))
+			}
+			for _, submesh := range data.Submeshes {
+				if submesh.Start == e && submesh.Count > 0 && submesh.Material != material {
+					material = submesh.Material
+					out.line("usemtl", material)
+				}
+			}
+		}
+
+		if data.Smoothing != nil {
+			if s := data.Smoothing[e/3]; e == 0 || s != smoothing {
+				smoothing = s
+				if s == 0 {
+					out.line("s off")
+				} else {
+					out.line("s", s)
+				}
+			}
+		}
+
+		out.face(data, data.Indices[e:e+3])
+	}
+
+	if out.err != nil {
+		return out.err
+	}
+	return out.w.Flush()
+}
+
+// boundaries returns the sorted element positions where
+// an object, group or submesh starts or ends
+func (data *Data) boundaries() []int {
+	var positions []int
+	for _, ranges := range [][]Group{data.Objects, data.Groups} {
+		for _, r := range ranges {
+			positions = append(positions, r.Start, r.Start+r.Count)
+		}
+	}
+	for _, submesh := range data.Submeshes {
+		positions = append(positions, submesh.Start, submesh.Start+submesh.Count)
+	}
+	sort.Ints(positions)
+
+	unique := positions[:0]
+	for i, p := range positions {
+		if i == 0 || p != positions[i-1] {
+			unique = append(unique, p)
+		}
+	}
+	return unique
+}
+
+// activeNames returns the names of groups that contain element e
+func activeNames(groups []Group, e int) []string {
+	var names []string
+	for _, group := range groups {
+		if group.Start <= e && e < group.Start+group.Count {
+			names = append(names, group.Name)
+		}
+	}
+	return names
+}
+
+// WriteMaterials writes materials in Wavefront MTL format,
+// sorted by name.
+func WriteMaterials(w io.Writer, materials map[string]*Material) error {
+	var names []string
+	for name := range materials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := &writer{w: bufio.NewWriter(w)}
+	for i, name := range names {
+		m := materials[name]
+		if i > 0 {
+			out.line()
+		}
+		out.line("newmtl", name)
+		out.line("Ka", m.Ambient[0], m.Ambient[1], m.Ambient[2])
+		out.line("Kd", m.Diffuse[0], m.Diffuse[1], m.Diffuse[2])
+		out.line("Ks", m.Specular[0], m.Specular[1], m.Specular[2])
+		out.line("Ns", m.Shininess)
+		out.line("d", m.Dissolve)
+		out.line("illum", m.Illum)
+
+		for _, texture := range []struct{ statement, file string }{
+			{"map_Kd", m.DiffuseMap},
+			{"map_Ks", m.SpecularMap},
+			{"map_Bump", m.BumpMap},
+			{"map_d", m.AlphaMap},
+		} {
+			if texture.file != "" {
+				out.line(texture.statement, texture.file)
+			}
+		}
+	}
+
+	if out.err != nil {
+		return out.err
+	}
+	return out.w.Flush()
+}
+
+// writer formats OBJ statements and remembers the first error
+type writer struct {
+	w   *bufio.Writer
+	buf []byte
+	err error
+}
+
+// line writes values separated by spaces
+func (out *writer) line(values ...interface{}) {
+	out.buf = out.buf[:0]
+	for i, value := range values {
+		if i > 0 {
+			out.buf = append(out.buf, ' ')
+		}
+		switch value := value.(type) {
+		case float32:
+			out.buf = strconv.AppendFloat(out.buf, float64(value), 'g', -1, 32)
+		default:
+			out.buf = append(out.buf, fmt.Sprint(value)...)
+		}
+	}
+	out.write()
+}
+
+// floats writes a statement for each size values
+func (out *writer) floats(statement string, values []float32, size int) {
+	for i := 0; i+size <= len(values); i += size {
+		out.buf = append(out.buf[:0], statement...)
+		for _, v := range values[i : i+size] {
+			out.buf = append(out.buf, ' ')
+			out.buf = strconv.AppendFloat(out.buf, float64(v), 'g', -1, 32)
+		}
+		out.write()
+	}
+}
+
+// face writes a triangle, referencing the uv and normal
+// with the same index as the vertex
+func (out *writer) face(data *Data, indices []uint32) {
+	var number [20]byte
+
+	out.buf = append(out.buf[:0], 'f')
+	for _, index := range indices {
+		i := strconv.AppendUint(number[:0], uint64(index)+1, 10)
+
+		out.buf = append(out.buf, ' ')
+		out.buf = append(out.buf, i...)
+		switch {
+		case data.UV != nil && data.Normal != nil:
+			out.buf = append(append(append(append(out.buf, '/'), i...), '/'), i...)
+		case data.UV != nil:
+			out.buf = append(append(out.buf, '/'), i...)
+		case data.Normal != nil:
+			out.buf = append(append(out.buf, '/', '/'), i...)
+		}
+	}
+	out.write()
+}
+
+func (out *writer) write() {
+	if out.err != nil {
+		return
+	}
+	out.buf = append(out.buf, '\n')
+	_, out.err = out.w.Write(out.buf)
+}
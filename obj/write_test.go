@@ -0,0 +1,121 @@
+package obj
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestWriteRoundTrip(t *testing.T) {
+	const src = `
+mtllib scene.mtl
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+v 0.5 0.5 1
+vt 0 0
+vt 1 0
+vt 1 1
+vt 0 1
+vn 0 0 1
+vn 0 0.7071 0.7071
+f 1/1/1 2/2/1 3/3/1 4/4/1
+o pyramid
+g side
+usemtl red
+s 1
+f 1/1/2 2/2/2 5/3/2
+f 2/2/2 3/3/2 5/3/2
+g side top
+s off
+f 3/3/2 4/4/2 5/3/2
+usemtl
+o
+g
+f 4 1 5
+`
+	for _, opts := range []Options{{}, DefaultOptions, {ZUp: true, Scale: 2, ReverseWinding: true}} {
+		data, err := LoadWithOptions(strings.NewReader(src), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if err := WriteWithOptions(&buf, data, opts); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadWithOptions(&buf, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(loaded, data) {
+			t.Errorf("%+v: reloaded data differs:\n%+v\n%+v", opts, loaded, data)
+		}
+	}
+}
+
+func TestWriteObjectReset(t *testing.T) {
+	// the second triangle is not in an object
+	data := &Data{
+		Vertex:    []float32{0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 1, 0, 1, 0, 0},
+		Submeshes: []Submesh{{"", 0, 6}},
+		Objects:   []Group{{"first", 0, 3}},
+		Materials: map[string]*Material{},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteWithOptions(&buf, data, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "\no\nf ") {
+		t.Errorf("missing object reset:\n%v", buf.String())
+	}
+
+	loaded, err := LoadWithOptions(&buf, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Objects, data.Objects) {
+		t.Errorf("got objects %v, expected %v", loaded.Objects, data.Objects)
+	}
+}
+
+func TestWriteMaterials(t *testing.T) {
+	materials := map[string]*Material{
+		"red plastic": {
+			Name:        "red plastic",
+			Ambient:     mgl32.Vec3{0.1, 0.1, 0.1},
+			Diffuse:     mgl32.Vec3{1, 0, 0},
+			Specular:    mgl32.Vec3{0.5, 0.5, 0.5},
+			Shininess:   96,
+			Dissolve:    0.5,
+			Illum:       2,
+			DiffuseMap:  "red texture.png",
+			SpecularMap: "spec.png",
+			BumpMap:     "normal.png",
+			AlphaMap:    "alpha.png",
+		},
+		"default": NewMaterial("default"),
+	}
+
+	var buf bytes.Buffer
+	if err := WriteMaterials(&buf, materials); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "newmtl default\n") {
+		t.Errorf("materials are not sorted:\n%v", buf.String())
+	}
+
+	loaded, err := LoadMaterials(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, materials) {
+		t.Errorf("reloaded materials differ:\n%+v\n%+v", loaded, materials)
+	}
+}
@@ -0,0 +1,176 @@
+package shaders
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// CompileError is returned when a shader fails to compile.
+type CompileError struct {
+	// Type is the shader type, such as gl.VERTEX_SHADER
+	Type uint32
+	// File is the name of the source file, empty when
+	// the shader was compiled from a string
+	File string
+	// Log is the info log of the driver
+	Log string
+	// Diagnostics are the parsed lines of Log
+	Diagnostics []Diagnostic
+}
+
+// Diagnostic is a single message from the info log.
+type Diagnostic struct {
+	// Line and Column are 1-based, 0 when the driver does not report them
+	Line   int
+	Column int
+	// Severity is "error" or "warning", empty when unknown
+	Severity string
+	Message  string
+	// Excerpt contains the source lines around Line
+	Excerpt string
+}
+
+// Stage returns the name of the shader stage.
+func (err *CompileError) Stage() string {
+	switch err.Type {
+	case gl.VERTEX_SHADER:
+		return "vertex"
+	case gl.TESS_CONTROL_SHADER:
+		return "tessellation control"
+	case gl.TESS_EVALUATION_SHADER:
+		return "tessellation evaluation"
+	case gl.GEOMETRY_SHADER:
+		return "geometry"
+	case gl.FRAGMENT_SHADER:
+		return "fragment"
+	}
+	return fmt.Sprintf("0x%x", err.Type)
+}
+
+func (err *CompileError) Error() string {
+	var b strings.Builder
+	name := err.Stage() + " shader"
+	if err.File != "" {
+		name = err.File
+	}
+
+	fmt.Fprintf(&b, "Compiling %v shader", err.Stage())
+	if err.File != "" {
+		fmt.Fprintf(&b, " %v", err.File)
+	}
+	b.WriteString(" failed:")
+	if len(err.Diagnostics) == 0 {
+		b.WriteString(" no info log")
+	}
+
+	for _, diagnostic := range err.Diagnostics {
+		b.WriteString("\n")
+		switch {
+		case diagnostic.Column > 0:
+			fmt.Fprintf(&b, "%v:%d:%d: ", name, diagnostic.Line, diagnostic.Column)
+		case diagnostic.Line > 0:
+			fmt.Fprintf(&b, "%v:%d: ", name, diagnostic.Line)
+		}
+		if diagnostic.Severity != "" {
+			fmt.Fprintf(&b, "%v: ", diagnostic.Severity)
+		}
+		b.WriteString(diagnostic.Message)
+		if diagnostic.Excerpt != "" {
+			b.WriteString("\n")
+			b.WriteString(diagnostic.Excerpt)
+		}
+	}
+	return b.String()
+}
+
+// info log formats, the first number is the index of the
+// source string and is ignored, since there is only one
+var (
+	// NVIDIA: 0(12) : error C1008: undefined variable "foo"
+	nvidiaDiagnostic = regexp.MustCompile(`^\d+\((\d+)\)\s*:\s*(.*)$`)
+	// Mesa: 0:12(5): error: `foo' undeclared
+	mesaDiagnostic = regexp.MustCompile(`^\d+:(\d+)\((\d+)\)\s*:\s*(.*)$`)
+	// AMD, Intel on Windows and Apple: ERROR: 0:12: 'foo' : undeclared identifier
+	glslangDiagnostic = regexp.MustCompile(`^(ERROR|WARNING):\s*\d+:(\d+):\s*(.*)$`)
+)
+
+// parseLog parses the info log, lines in an unknown
+// format are kept as messages without a line
+func parseLog(log, source string) []Diagnostic {
+	sourceLines := strings.Split(strings.TrimSuffix(source, "\n"), "\n")
+
+	var diagnostics []Diagnostic
+	for _, line := range strings.Split(log, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		var diagnostic Diagnostic
+		if match := mesaDiagnostic.FindStringSubmatch(line); match != nil {
+			diagnostic.Line, _ = strconv.Atoi(match[1])
+			diagnostic.Column, _ = strconv.Atoi(match[2])
+			diagnostic.Severity, diagnostic.Message = splitSeverity(match[3])
+		} else if match := nvidiaDiagnostic.FindStringSubmatch(line); match != nil {
+			diagnostic.Line, _ = strconv.Atoi(match[1])
+			diagnostic.Severity, diagnostic.Message = splitSeverity(match[2])
+		} else if match := glslangDiagnostic.FindStringSubmatch(line); match != nil {
+			diagnostic.Line, _ = strconv.Atoi(match[2])
+			diagnostic.Severity = strings.ToLower(match[1])
+			diagnostic.Message = match[3]
+		} else {
+			diagnostic.Message = line
+		}
+
+		diagnostic.Excerpt = excerpt(sourceLines, diagnostic.Line, 2)
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return diagnostics
+}
+
+// splitSeverity splits "error C1008: message" or "error: message"
+// into the severity and the message, the error code is kept
+func splitSeverity(message string) (severity, rest string) {
+	for _, severity := range []string{"error", "warning"} {
+		if strings.HasPrefix(message, severity) {
+			rest = strings.TrimPrefix(message, severity)
+			rest = strings.TrimPrefix(rest, ":")
+			return severity, strings.TrimSpace(rest)
+		}
+	}
+	return "", message
+}
+
+// excerpt returns context lines before and after line,
+// the line itself is marked with ">"
+func excerpt(lines []string, line, context int) string {
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+
+	first, last := line-context, line+context
+	if first < 1 {
+		first = 1
+	}
+	if last > len(lines) {
+		last = len(lines)
+	}
+
+	width := len(strconv.Itoa(last))
+	var b strings.Builder
+	for i := first; i <= last; i++ {
+		marker := " "
+		if i == line {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "%v %*d | %v", marker, width, i, strings.TrimRight(lines[i-1], "\r"))
+		if i < last {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,122 @@
+package shaders
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+const testSource = "#version 330 core\nin vec3 position;\nvoid main() {\n\tgl_Position = foo;\n}\n"
+
+func TestParseLog(t *testing.T) {
+	// excerpt of line 4 in testSource
+	line4 := "  2 | in vec3 position;\n  3 | void main() {\n> 4 | \tgl_Position = foo;\n  5 | }"
+
+	tests := []struct {
+		name     string
+		log      string
+		expected []Diagnostic
+	}{
+		{
+			name: "NVIDIA",
+			log:  "0(4) : error C1008: undefined variable \"foo\"\n0(1) : warning C7050: unused\n",
+			expected: []Diagnostic{
+				{Line: 4, Severity: "error", Message: `C1008: undefined variable "foo"`, Excerpt: line4},
+				{Line: 1, Severity: "warning", Message: "C7050: unused", Excerpt: "> 1 | #version 330 core\n  2 | in vec3 position;\n  3 | void main() {"},
+			},
+		},
+		{
+			name: "Mesa",
+			log:  "0:4(16): error: `foo' undeclared\n",
+			expected: []Diagnostic{
+				{Line: 4, Column: 16, Severity: "error", Message: "`foo' undeclared", Excerpt: line4},
+			},
+		},
+		{
+			name: "glslang",
+			log:  "ERROR: 0:4: 'foo' : undeclared identifier \nWARNING: 0:5: 'main' : unreachable\n",
+			expected: []Diagnostic{
+				{Line: 4, Severity: "error", Message: "'foo' : undeclared identifier", Excerpt: line4},
+				{Line: 5, Severity: "warning", Message: "'main' : unreachable", Excerpt: "  3 | void main() {\n  4 | \tgl_Position = foo;\n> 5 | }"},
+			},
+		},
+		{
+			name: "unmatched lines",
+			log:  "ERROR: 1 compilation errors.  No code generated.\n\n  Link failed  \n",
+			expected: []Diagnostic{
+				{Message: "ERROR: 1 compilation errors.  No code generated."},
+				{Message: "Link failed"},
+			},
+		},
+		{
+			name: "line outside source",
+			log:  "0(40) : error C0000: syntax error",
+			expected: []Diagnostic{
+				{Line: 40, Severity: "error", Message: "C0000: syntax error"},
+			},
+		},
+		{
+			name: "unknown severity",
+			log:  "0:2(1): note: declared here",
+			expected: []Diagnostic{
+				{Line: 2, Column: 1, Message: "note: declared here", Excerpt: "  1 | #version 330 core\n> 2 | in vec3 position;\n  3 | void main() {\n  4 | \tgl_Position = foo;"},
+			},
+		},
+		{
+			name: "empty",
+			log:  "",
+		},
+	}
+
+	for _, test := range tests {
+		got := parseLog(test.log, testSource)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%v: got\n%#v\nexpected\n%#v", test.name, got, test.expected)
+		}
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	lines := []string{"a", "b\r", "c", "d", "e", "f", "g", "h", "i", "j", "k"}
+
+	tests := []struct {
+		line     int
+		context  int
+		expected string
+	}{
+		{0, 2, ""},
+		{12, 2, ""},
+		{1, 0, "> 1 | a"},
+		{2, 1, "  1 | a\n> 2 | b\n  3 | c"},
+		{10, 2, "   8 | h\n   9 | i\n> 10 | j\n  11 | k"},
+	}
+
+	for _, test := range tests {
+		if got := excerpt(lines, test.line, test.context); got != test.expected {
+			t.Errorf("line %d: got\n%v\nexpected\n%v", test.line, got, test.expected)
+		}
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	err := &CompileError{
+		Type: gl.FRAGMENT_SHADER,
+		File: "shader.frag",
+		Diagnostics: []Diagnostic{
+			{Line: 4, Column: 16, Severity: "error", Message: "`foo' undeclared", Excerpt: "> 4 | x"},
+			{Message: "Link failed"},
+		},
+	}
+	expected := "Compiling fragment shader shader.frag failed:\n" +
+		"shader.frag:4:16: error: `foo' undeclared\n> 4 | x\n" +
+		"Link failed"
+	if got := err.Error(); got != expected {
+		t.Errorf("got\n%v\nexpected\n%v", got, expected)
+	}
+
+	err = &CompileError{Type: gl.VERTEX_SHADER}
+	if got, expected := err.Error(), "Compiling vertex shader failed: no info log"; got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
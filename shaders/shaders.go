@@ -11,18 +11,17 @@ import (
 )
 
 func Load(vertexShaderFile, fragmentShaderFile string) (uint32, error) {
-	vertexShaderSource, err := ioutil.ReadFile(vertexShaderFile)
+	vertexShader, err := CompileFile(vertexShaderFile, gl.VERTEX_SHADER)
 	if err != nil {
 		return 0, err
 	}
-	fragmentShaderSource, err := ioutil.ReadFile(fragmentShaderFile)
+
+	fragmentShader, err := CompileFile(fragmentShaderFile, gl.FRAGMENT_SHADER)
 	if err != nil {
 		return 0, err
 	}
 
-	return CreateProgram(
-		string(vertexShaderSource)+"\x00",
-		string(fragmentShaderSource)+"\x00")
+	return linkProgram(vertexShader, fragmentShader)
 }
 
 func CreateProgram(vertexShaderSource, fragmentShaderSource string) (uint32, error) {
@@ -36,6 +35,10 @@ func CreateProgram(vertexShaderSource, fragmentShaderSource string) (uint32, err
 		return 0, err
 	}
 
+	return linkProgram(vertexShader, fragmentShader)
+}
+
+func linkProgram(vertexShader, fragmentShader uint32) (uint32, error) {
 	program := gl.CreateProgram()
 	gl.AttachShader(program, vertexShader)
 	gl.AttachShader(program, fragmentShader)
@@ -56,7 +59,23 @@ func CreateProgram(vertexShaderSource, fragmentShaderSource string) (uint32, err
 	return program, nil
 }
 
+// CompileFile compiles the shader in filename,
+// errors are reported as *CompileError.
+func CompileFile(filename string, shaderType uint32) (uint32, error) {
+	source, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	return compile(string(source)+"\x00", shaderType, filename)
+}
+
+// CompileShader compiles a null terminated source,
+// errors are reported as *CompileError.
 func CompileShader(source string, shaderType uint32) (uint32, error) {
+	return compile(source, shaderType, "")
+}
+
+func compile(source string, shaderType uint32, filename string) (uint32, error) {
 	shader := gl.CreateShader(shaderType)
 
 	csource := gl.Str(source)
@@ -71,8 +90,15 @@ func CompileShader(source string, shaderType uint32) (uint32, error) {
 
 		log := strings.Repeat("\x00", int(length+1))
 		gl.GetShaderInfoLog(shader, length, nil, gl.Str(log))
-
-		return 0, fmt.Errorf("Compiling %v failed: %v", source, log)
+		gl.DeleteShader(shader)
+
+		log = strings.TrimRight(log, "\x00")
+		return 0, &CompileError{
+			Type:        shaderType,
+			File:        filename,
+			Log:         log,
+			Diagnostics: parseLog(log, strings.TrimRight(source, "\x00")),
+		}
 	}
 
 	return shader, nil
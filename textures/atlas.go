@@ -0,0 +1,317 @@
+package textures
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"sort"
+)
+
+// AtlasOptions configure how PackAtlas places the images.
+type AtlasOptions struct {
+	// MaxWidth and MaxHeight limit the size of the atlas, 0 means 4096
+	MaxWidth  int
+	MaxHeight int
+
+	// Padding is the number of transparent pixels between images
+	Padding int
+	// Extrude repeats the edge pixels of each image outwards, which
+	// stops neighbouring images bleeding in with linear filtering;
+	// the extruded pixels are not part of the region
+	Extrude int
+
+	// PowerOfTwo rounds the atlas size up to powers of two,
+	// otherwise the atlas is cropped to the placed images
+	PowerOfTwo bool
+}
+
+// DefaultAtlasOptions leave enough space between the images
+// for linear filtering without mipmaps.
+var DefaultAtlasOptions = AtlasOptions{
+	MaxWidth:  4096,
+	MaxHeight: 4096,
+	Padding:   2,
+	Extrude:   1,
+}
+
+// Atlas is a single image containing many smaller images.
+//
+// The regions are tagged for encoding/json, so the layout can be
+// saved next to the atlas image.
+type Atlas struct {
+	Image   *image.NRGBA      `json:"-"`
+	Width   int               `json:"width"`
+	Height  int               `json:"height"`
+	Regions map[string]Region `json:"regions"`
+}
+
+// Region is the location of an image in the atlas.
+type Region struct {
+	// X, Y, Width and Height are in pixels
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// U0, V0 is the top-left and U1, V1 the bottom-right corner in
+	// texture coordinates; V grows downwards, as the atlas is
+	// uploaded without Options.FlipY
+	U0 float32 `json:"u0"`
+	V0 float32 `json:"v0"`
+	U1 float32 `json:"u1"`
+	V1 float32 `json:"v1"`
+}
+
+// Rect returns the region in pixels.
+func (region Region) Rect() image.Rectangle {
+	return image.Rect(region.X, region.Y, region.X+region.Width, region.Y+region.Height)
+}
+
+// PackAtlas places the images into a single atlas with a MaxRects
+// bin packer and draws them into it. The atlas starts from the
+// smallest power-of-two size that could fit the images and grows
+// until they fit or the maximum size is reached. Images are not
+// rotated. The atlas can be uploaded with Upload.
+func PackAtlas(images map[string]image.Image, opts AtlasOptions) (*Atlas, error) {
+	if len(images) == 0 {
+		return nil, errors.New("No images to pack")
+	}
+	if opts.MaxWidth == 0 {
+		opts.MaxWidth = 4096
+	}
+	if opts.MaxHeight == 0 {
+		opts.MaxHeight = 4096
+	}
+	if opts.MaxWidth < 0 || opts.MaxHeight < 0 || opts.Padding < 0 || opts.Extrude < 0 {
+		return nil, fmt.Errorf("Invalid atlas options %+v", opts)
+	}
+
+	// larger images are placed first, names make the order stable
+	names := make([]string, 0, len(images))
+	for name := range images {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := images[names[i]].Bounds().Size(), images[names[j]].Bounds().Size()
+		if maxInt(a.X, a.Y) != maxInt(b.X, b.Y) {
+			return maxInt(a.X, a.Y) > maxInt(b.X, b.Y)
+		}
+		if a.X*a.Y != b.X*b.Y {
+			return a.X*a.Y > b.X*b.Y
+		}
+		return names[i] < names[j]
+	})
+
+	// each cell contains the image, its extrusion on all sides and
+	// the padding on the right and bottom
+	cells := make([]image.Point, len(names))
+	var area, widest, tallest int
+	for i, name := range names {
+		size := images[name].Bounds().Size()
+		if size.X <= 0 || size.Y <= 0 {
+			return nil, fmt.Errorf("Image %q is empty", name)
+		}
+		cells[i] = size.Add(image.Pt(2*opts.Extrude+opts.Padding, 2*opts.Extrude+opts.Padding))
+		area += cells[i].X * cells[i].Y
+		widest, tallest = maxInt(widest, cells[i].X), maxInt(tallest, cells[i].Y)
+	}
+
+	width := minInt(nextPowerOfTwo(maxInt(widest-opts.Padding, int(math.Sqrt(float64(area))))), opts.MaxWidth)
+	height := minInt(nextPowerOfTwo(maxInt(tallest-opts.Padding, area/maxInt(width, 1))), opts.MaxHeight)
+	for {
+		if positions, ok := packCells(cells, width, height, opts.Padding); ok {
+			return drawAtlas(images, names, positions, width, height, opts), nil
+		}
+
+		// grow the shorter side first to keep the atlas square
+		switch {
+		case width <= height && width < opts.MaxWidth:
+			width = minInt(2*width, opts.MaxWidth)
+		case height < opts.MaxHeight:
+			height = minInt(2*height, opts.MaxHeight)
+		case width < opts.MaxWidth:
+			width = minInt(2*width, opts.MaxWidth)
+		default:
+			return nil, fmt.Errorf("Images do not fit into %dx%d atlas", opts.MaxWidth, opts.MaxHeight)
+		}
+	}
+}
+
+// packCells places the cells into a width x height atlas, the
+// padding of the last row and column may fall outside of it
+func packCells(cells []image.Point, width, height, padding int) ([]image.Point, bool) {
+	bin := newMaxRects(width+padding, height+padding)
+	positions := make([]image.Point, len(cells))
+	for i, cell := range cells {
+		position, ok := bin.insert(cell)
+		if !ok {
+			return nil, false
+		}
+		positions[i] = position
+	}
+	return positions, true
+}
+
+// drawAtlas copies the images to their positions
+func drawAtlas(images map[string]image.Image, names []string, positions []image.Point, width, height int, opts AtlasOptions) *Atlas {
+	extrude := image.Pt(opts.Extrude, opts.Extrude)
+
+	if !opts.PowerOfTwo {
+		// crop to the placed images
+		used := image.Point{}
+		for i, name := range names {
+			size := images[name].Bounds().Size()
+			max := positions[i].Add(size).Add(extrude.Mul(2))
+			used.X, used.Y = maxInt(used.X, max.X), maxInt(used.Y, max.Y)
+		}
+		width, height = used.X, used.Y
+	}
+
+	atlas := &Atlas{
+		Image:   image.NewNRGBA(image.Rect(0, 0, width, height)),
+		Width:   width,
+		Height:  height,
+		Regions: make(map[string]Region, len(names)),
+	}
+	for i, name := range names {
+		img := images[name]
+		bounds := img.Bounds()
+		r := image.Rectangle{positions[i].Add(extrude), positions[i].Add(extrude).Add(bounds.Size())}
+
+		draw.Draw(atlas.Image, r, img, bounds.Min, draw.Src)
+		extrudeEdges(atlas.Image, r, opts.Extrude)
+
+		atlas.Regions[name] = Region{
+			X:      r.Min.X,
+			Y:      r.Min.Y,
+			Width:  r.Dx(),
+			Height: r.Dy(),
+			U0:     float32(r.Min.X) / float32(width),
+			V0:     float32(r.Min.Y) / float32(height),
+			U1:     float32(r.Max.X) / float32(width),
+			V1:     float32(r.Max.Y) / float32(height),
+		}
+	}
+	return atlas
+}
+
+// extrudeEdges copies the edge pixels of r outwards by n pixels,
+// first the columns and then the rows, which also fills the corners
+func extrudeEdges(img *image.NRGBA, r image.Rectangle, n int) {
+	if n == 0 {
+		return
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		left := img.Pix[img.PixOffset(r.Min.X, y):][:4]
+		right := img.Pix[img.PixOffset(r.Max.X-1, y):][:4]
+		for k := 1; k <= n; k++ {
+			copy(img.Pix[img.PixOffset(r.Min.X-k, y):], left)
+			copy(img.Pix[img.PixOffset(r.Max.X-1+k, y):], right)
+		}
+	}
+
+	rowSize := 4 * (r.Dx() + 2*n)
+	top := img.Pix[img.PixOffset(r.Min.X-n, r.Min.Y):][:rowSize]
+	bottom := img.Pix[img.PixOffset(r.Min.X-n, r.Max.Y-1):][:rowSize]
+	for k := 1; k <= n; k++ {
+		copy(img.Pix[img.PixOffset(r.Min.X-n, r.Min.Y-k):], top)
+		copy(img.Pix[img.PixOffset(r.Min.X-n, r.Max.Y-1+k):], bottom)
+	}
+}
+
+// maxRects tracks the maximal free rectangles of a bin, a placed
+// rectangle splits every free rectangle it overlaps
+type maxRects struct {
+	free []image.Rectangle
+}
+
+func newMaxRects(width, height int) *maxRects {
+	return &maxRects{free: []image.Rectangle{image.Rect(0, 0, width, height)}}
+}
+
+// insert places a rectangle of the given size using the best short
+// side fit heuristic and returns its top-left corner
+func (bin *maxRects) insert(size image.Point) (image.Point, bool) {
+	best := -1
+	bestShort, bestLong := math.MaxInt32, math.MaxInt32
+	for i, r := range bin.free {
+		if r.Dx() < size.X || r.Dy() < size.Y {
+			continue
+		}
+		dx, dy := r.Dx()-size.X, r.Dy()-size.Y
+		short, long := minInt(dx, dy), maxInt(dx, dy)
+		if short < bestShort || (short == bestShort && long < bestLong) {
+			best, bestShort, bestLong = i, short, long
+		}
+	}
+	if best < 0 {
+		return image.Point{}, false
+	}
+
+	min := bin.free[best].Min
+	bin.place(image.Rectangle{min, min.Add(size)})
+	return min, true
+}
+
+// place removes used from the free rectangles
+func (bin *maxRects) place(used image.Rectangle) {
+	var free []image.Rectangle
+	for _, r := range bin.free {
+		if !r.Overlaps(used) {
+			free = append(free, r)
+			continue
+		}
+		if used.Min.X > r.Min.X {
+			free = append(free, image.Rect(r.Min.X, r.Min.Y, used.Min.X, r.Max.Y))
+		}
+		if used.Max.X < r.Max.X {
+			free = append(free, image.Rect(used.Max.X, r.Min.Y, r.Max.X, r.Max.Y))
+		}
+		if used.Min.Y > r.Min.Y {
+			free = append(free, image.Rect(r.Min.X, r.Min.Y, r.Max.X, used.Min.Y))
+		}
+		if used.Max.Y < r.Max.Y {
+			free = append(free, image.Rect(r.Min.X, used.Max.Y, r.Max.X, r.Max.Y))
+		}
+	}
+
+	// rectangles inside other ones are redundant,
+	// of equal rectangles the first one is kept
+	bin.free = bin.free[:0]
+	for i, r := range free {
+		contained := false
+		for j, other := range free {
+			if i != j && r.In(other) && (r != other || j < i) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			bin.free = append(bin.free, r)
+		}
+	}
+}
+
+func nextPowerOfTwo(v int) int {
+	p := 1
+	for p < v {
+		p *= 2
+	}
+	return p
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
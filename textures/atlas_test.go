@@ -0,0 +1,108 @@
+package textures
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestPackAtlas(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	images := map[string]image.Image{}
+	for i := 0; i < 40; i++ {
+		name := string(rune('A' + i))
+		images[name] = coordinates(1+rng.Intn(40), 1+rng.Intn(40))
+	}
+
+	for _, opts := range []AtlasOptions{
+		{},
+		DefaultAtlasOptions,
+		{Padding: 3, Extrude: 2, PowerOfTwo: true},
+	} {
+		atlas, err := PackAtlas(images, opts)
+		if err != nil {
+			t.Fatalf("%+v: %v", opts, err)
+		}
+		if atlas.Image.Bounds() != image.Rect(0, 0, atlas.Width, atlas.Height) || len(atlas.Regions) != len(images) {
+			t.Fatalf("%+v: got %dx%d atlas with %d regions", opts, atlas.Width, atlas.Height, len(atlas.Regions))
+		}
+		if opts.PowerOfTwo && (nextPowerOfTwo(atlas.Width) != atlas.Width || nextPowerOfTwo(atlas.Height) != atlas.Height) {
+			t.Errorf("%+v: %dx%d is not a power of two", opts, atlas.Width, atlas.Height)
+		}
+
+		for name, region := range atlas.Regions {
+			img := images[name].(*image.NRGBA)
+			if region.Rect().Size() != img.Bounds().Size() {
+				t.Errorf("%+v: %v is %v, expected %v", opts, name, region.Rect().Size(), img.Bounds().Size())
+			}
+
+			// the extruded pixels must be inside the atlas
+			outer := region.Rect().Inset(-opts.Extrude)
+			if !outer.In(atlas.Image.Bounds()) {
+				t.Errorf("%+v: %v at %v is outside of the atlas", opts, name, outer)
+			}
+			for other, o := range atlas.Regions {
+				if other != name && outer.Inset(-opts.Padding).Overlaps(o.Rect().Inset(-opts.Extrude)) {
+					t.Errorf("%+v: %v overlaps %v", opts, name, other)
+				}
+			}
+
+			// the pixels are copied and the corners extruded
+			size := img.Bounds().Size()
+			for _, p := range []image.Point{{0, 0}, {size.X - 1, size.Y - 1}, {size.X / 2, size.Y / 2}} {
+				if got := atlas.Image.NRGBAAt(region.X+p.X, region.Y+p.Y); got != img.NRGBAAt(p.X, p.Y) {
+					t.Errorf("%+v: %v pixel %v is %v, expected %v", opts, name, p, got, img.NRGBAAt(p.X, p.Y))
+				}
+			}
+			if got := atlas.Image.NRGBAAt(outer.Min.X, outer.Min.Y); got != img.NRGBAAt(0, 0) {
+				t.Errorf("%+v: %v top-left corner is %v, expected %v", opts, name, got, img.NRGBAAt(0, 0))
+			}
+			if got := atlas.Image.NRGBAAt(outer.Max.X-1, outer.Max.Y-1); got != img.NRGBAAt(size.X-1, size.Y-1) {
+				t.Errorf("%+v: %v bottom-right corner is %v, expected %v", opts, name, got, img.NRGBAAt(size.X-1, size.Y-1))
+			}
+
+			if region.U0 != float32(region.X)/float32(atlas.Width) || region.V1 != float32(region.Y+region.Height)/float32(atlas.Height) {
+				t.Errorf("%+v: %v has texture coordinates %+v", opts, name, region)
+			}
+		}
+	}
+}
+
+func TestPackAtlasSingle(t *testing.T) {
+	atlas, err := PackAtlas(map[string]image.Image{
+		"a": filledImage(2, 3, color.NRGBA{1, 2, 3, 4}),
+	}, AtlasOptions{Extrude: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Region{X: 1, Y: 1, Width: 2, Height: 3, U0: 0.25, V0: 0.2, U1: 0.75, V1: 0.8}
+	if atlas.Width != 4 || atlas.Height != 5 || !reflect.DeepEqual(atlas.Regions["a"], expected) {
+		t.Errorf("got %dx%d %+v, expected 4x5 %+v", atlas.Width, atlas.Height, atlas.Regions["a"], expected)
+	}
+}
+
+func TestPackAtlasErrors(t *testing.T) {
+	large := map[string]image.Image{
+		"a": filledImage(40, 40, color.NRGBA{}),
+		"b": filledImage(40, 40, color.NRGBA{}),
+	}
+	tests := []struct {
+		name   string
+		images map[string]image.Image
+		opts   AtlasOptions
+	}{
+		{"no images", nil, AtlasOptions{}},
+		{"empty image", map[string]image.Image{"a": filledImage(0, 3, color.NRGBA{})}, AtlasOptions{}},
+		{"negative padding", large, AtlasOptions{Padding: -1}},
+		{"too small", large, AtlasOptions{MaxWidth: 64, MaxHeight: 64}},
+	}
+
+	for _, test := range tests {
+		if _, err := PackAtlas(test.images, test.opts); err == nil {
+			t.Errorf("%v: did not fail", test.name)
+		}
+	}
+}
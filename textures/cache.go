@@ -0,0 +1,185 @@
+package textures
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+
+	"github.com/egonelbre/opengl-tutorial.org/dds"
+	"github.com/egonelbre/opengl-tutorial.org/ktx"
+)
+
+// Cache loads each file once and shares the texture between
+// everyone who loads it. The texture is deleted when the last
+// reference is released.
+//
+// Like OpenGL calls, a Cache must only be used from the
+// thread that owns the context.
+type Cache struct {
+	// Options are used for uploading images, DDS and KTX
+	// files only use the wrap modes and filters, since they
+	// contain their own format and mipmaps
+	Options Options
+
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	path    string
+	texture uint32
+	target  uint32
+	refs    int
+}
+
+// NewCache returns an empty cache, which uploads images with opts.
+func NewCache(opts Options) *Cache {
+	return &Cache{
+		Options: opts,
+		entries: make(map[string]*cacheEntry),
+	}
+}
+
+// Texture is a reference to a texture in a Cache.
+type Texture struct {
+	cache *Cache
+	entry *cacheEntry
+}
+
+// ID returns the OpenGL texture, which is 0 after Release.
+func (texture *Texture) ID() uint32 {
+	if texture.entry == nil {
+		return 0
+	}
+	return texture.entry.texture
+}
+
+// Target returns the target to bind the texture to, such as
+// TEXTURE_2D or TEXTURE_CUBE_MAP.
+func (texture *Texture) Target() uint32 {
+	if texture.entry == nil {
+		return 0
+	}
+	return texture.entry.target
+}
+
+// Bind binds the texture to unit, 0 corresponds to gl.TEXTURE0.
+func (texture *Texture) Bind(unit uint32) {
+	gl.ActiveTexture(gl.TEXTURE0 + unit)
+	gl.BindTexture(texture.Target(), texture.ID())
+}
+
+// Release drops the reference, the texture is deleted when it was
+// the last one. Releasing the same Texture again does nothing.
+func (texture *Texture) Release() {
+	entry := texture.entry
+	if entry == nil {
+		return
+	}
+	texture.entry = nil
+
+	entry.refs--
+	// after Clear the path may belong to a newer entry
+	if entry.refs > 0 || texture.cache.entries[entry.path] != entry {
+		return
+	}
+	delete(texture.cache.entries, entry.path)
+	gl.DeleteTextures(1, &entry.texture)
+}
+
+// Load returns a reference to the texture of filename, the file
+// is only loaded when it is not in the cache. Paths are compared
+// after making them absolute.
+//
+// The format is detected from the content: DDS and KTX files are
+// uploaded as they are, other files are decoded with image.Decode
+// and uploaded with Upload.
+func (cache *Cache) Load(filename string) (*Texture, error) {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := cache.entries[path]
+	if !ok {
+		texture, target, err := loadFile(path, cache.Options)
+		if err != nil {
+			return nil, err
+		}
+		entry = &cacheEntry{path: path, texture: texture, target: target}
+		cache.entries[path] = entry
+	}
+
+	entry.refs++
+	return &Texture{cache: cache, entry: entry}, nil
+}
+
+// Len returns the number of textures in the cache.
+func (cache *Cache) Len() int { return len(cache.entries) }
+
+// Clear deletes all textures, even if they are still referenced.
+// Existing references return 0 afterwards.
+func (cache *Cache) Clear() {
+	for path, entry := range cache.entries {
+		gl.DeleteTextures(1, &entry.texture)
+		entry.texture, entry.target = 0, 0
+		delete(cache.entries, path)
+	}
+}
+
+// loadFile uploads a DDS, KTX or image file and returns
+// the texture and its target
+func loadFile(filename string, opts Options) (texture, target uint32, err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	br := bufio.NewReader(file)
+	magic, _ := br.Peek(12)
+
+	var img *dds.Image
+	switch {
+	case bytes.HasPrefix(magic, []byte("DDS ")):
+		img, err = dds.Decode(br)
+	case bytes.HasPrefix(magic, []byte("\xABKTX ")):
+		img, err = ktx.Decode(br)
+	default:
+		var decoded image.Image
+		decoded, _, err = image.Decode(br)
+		if err != nil {
+			return 0, 0, fmt.Errorf("Decoding %v failed: %v", filename, err)
+		}
+		texture, err = Upload(decoded, opts)
+		return texture, gl.TEXTURE_2D, err
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("Decoding %v failed: %v", filename, err)
+	}
+
+	// dds.Upload limits TEXTURE_MAX_LEVEL to the levels in the
+	// file, so mipmap filters can be used with any of them
+	opts = opts.withDefaults()
+	opts.Mipmaps = true
+	if err := opts.validate(); err != nil {
+		return 0, 0, err
+	}
+
+	gl.ActiveTexture(gl.TEXTURE0 + opts.Unit)
+	texture, err = dds.Upload(img)
+	if err != nil {
+		return 0, 0, err
+	}
+	target = img.Target()
+	opts.setParameters(target)
+	if err := glError("Uploading texture"); err != nil {
+		gl.DeleteTextures(1, &texture)
+		return 0, 0, err
+	}
+	return texture, target, nil
+}
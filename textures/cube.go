@@ -0,0 +1,154 @@
+package textures
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+// DefaultCubeOptions clamp the faces to the edges, which avoids
+// seams when the cube map is used as a skybox.
+var DefaultCubeOptions = Options{
+	WrapS:     gl.CLAMP_TO_EDGE,
+	WrapT:     gl.CLAMP_TO_EDGE,
+	WrapR:     gl.CLAMP_TO_EDGE,
+	MinFilter: gl.LINEAR_MIPMAP_LINEAR,
+	MagFilter: gl.LINEAR,
+	Mipmaps:   true,
+}
+
+// LoadCube loads a cube map using DefaultCubeOptions, see LoadCubeFaces.
+func LoadCube(filenames ...string) (uint32, error) {
+	return LoadCubeWithOptions(filenames, DefaultCubeOptions)
+}
+
+// LoadCubeWithOptions loads a cube map, see LoadCubeFaces.
+func LoadCubeWithOptions(filenames []string, opts Options) (uint32, error) {
+	faces, err := LoadCubeFaces(filenames...)
+	if err != nil {
+		return 0, err
+	}
+	return UploadCube(faces, opts)
+}
+
+// LoadCubeFaces decodes six face files in order +X, -X, +Y, -Y, +Z, -Z
+// or a single cross image and returns the faces, see CubeFaces.
+func LoadCubeFaces(filenames ...string) ([]image.Image, error) {
+	var images []image.Image
+	for _, filename := range filenames {
+		img, err := decodeFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	return CubeFaces(images...)
+}
+
+// CubeFaces returns the six faces of a cube map in order
+// +X, -X, +Y, -Y, +Z, -Z.
+//
+// The images are either the six faces, which must be square and of
+// equal size, or a single cross. A horizontal cross is four faces
+// wide and three high, a vertical cross three wide and four high:
+//
+//	    +Y                +Y
+//	-X  +Z  +X  -Z    -X  +Z  +X
+//	    -Y                -Y
+//	                      -Z
+//
+// In the vertical cross -Z is upside down, since it is folded
+// over the bottom, and it is rotated when sliced.
+func CubeFaces(images ...image.Image) ([]image.Image, error) {
+	switch len(images) {
+	case 1:
+		return sliceCross(images[0])
+	case 6:
+	default:
+		return nil, fmt.Errorf("Cube map needs 6 faces or a cross, got %d images", len(images))
+	}
+
+	size := images[0].Bounds().Size()
+	for i, img := range images {
+		if face := img.Bounds().Size(); face.X != face.Y || face != size {
+			return nil, fmt.Errorf("Face %d is %dx%d, expected square %dx%d", i, face.X, face.Y, size.X, size.Y)
+		}
+	}
+	if size.X == 0 {
+		return nil, fmt.Errorf("Invalid face size %dx%d", size.X, size.Y)
+	}
+	return images, nil
+}
+
+// cross layouts list the column and row of each face
+var (
+	horizontalCross = [6]image.Point{{2, 1}, {0, 1}, {1, 0}, {1, 2}, {1, 1}, {3, 1}}
+	verticalCross   = [6]image.Point{{2, 1}, {0, 1}, {1, 0}, {1, 2}, {1, 1}, {1, 3}}
+)
+
+// sliceCross cuts a horizontal or vertical cross into faces
+func sliceCross(img image.Image) ([]image.Image, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	var layout *[6]image.Point
+	var size int
+	switch {
+	case width*3 == height*4 && width%4 == 0:
+		layout, size = &horizontalCross, width/4
+	case width*4 == height*3 && width%3 == 0:
+		layout, size = &verticalCross, width/3
+	default:
+		return nil, fmt.Errorf("Image of size %dx%d is not a 4:3 or 3:4 cross", width, height)
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("Invalid cross size %dx%d", width, height)
+	}
+
+	faces := make([]image.Image, 6)
+	for i, cell := range layout {
+		min := bounds.Min.Add(cell.Mul(size))
+		faces[i] = copyFace(img, image.Rectangle{min, min.Add(image.Pt(size, size))}, layout == &verticalCross && i == 5)
+	}
+	return faces, nil
+}
+
+// copyFace copies r of img, optionally rotating it by 180 degrees
+func copyFace(img image.Image, r image.Rectangle, rotate bool) image.Image {
+	face := image.NewNRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(face, face.Rect, img, r.Min, draw.Src)
+	if !rotate {
+		return face
+	}
+
+	// rotating by 180 degrees reverses the order of the pixels
+	pix := face.Pix
+	for i, j := 0, len(pix)-4; i < j; i, j = i+4, j-4 {
+		for k := 0; k < 4; k++ {
+			pix[i+k], pix[j+k] = pix[j+k], pix[i+k]
+		}
+	}
+	return face
+}
+
+// UploadCube uploads six faces or a cross, see CubeFaces, as a new
+// TEXTURE_CUBE_MAP texture, which is left bound to opts.Unit.
+// The faces are prepared and mipmapped as in Upload.
+func UploadCube(faces []image.Image, opts Options) (uint32, error) {
+	faces, err := CubeFaces(faces...)
+	if err != nil {
+		return 0, err
+	}
+
+	var prepared [][]*Pixels
+	for _, face := range faces {
+		levels, err := prepareLevels(face, opts)
+		if err != nil {
+			return 0, err
+		}
+		prepared = append(prepared, levels)
+	}
+	return upload(gl.TEXTURE_CUBE_MAP, prepared, opts)
+}
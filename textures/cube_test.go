@@ -0,0 +1,94 @@
+package textures
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// coordinates returns an image where each pixel contains its position
+func coordinates(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	return img
+}
+
+func TestCubeFacesCross(t *testing.T) {
+	tests := []struct {
+		name string
+		img  image.Image
+		// cells are the columns and rows of the faces
+		cells [6]image.Point
+		// rotated is the face that is upside down
+		rotated int
+	}{
+		{"horizontal", coordinates(8, 6), horizontalCross, -1},
+		{"vertical", coordinates(6, 8), verticalCross, 5},
+		{"offset", coordinates(10, 8).SubImage(image.Rect(2, 2, 10, 8)), [6]image.Point{{3, 2}, {1, 2}, {2, 1}, {2, 3}, {2, 2}, {4, 2}}, -1},
+	}
+
+	for _, test := range tests {
+		faces, err := CubeFaces(test.img)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if len(faces) != 6 {
+			t.Errorf("%v: got %d faces", test.name, len(faces))
+			continue
+		}
+
+		for i, face := range faces {
+			if size := face.Bounds().Size(); size != image.Pt(2, 2) {
+				t.Errorf("%v: face %d is %v", test.name, i, size)
+				continue
+			}
+			min := test.cells[i].Mul(2)
+			first, last := min, min.Add(image.Pt(1, 1))
+			if i == test.rotated {
+				first, last = last, first
+			}
+
+			bounds := face.Bounds()
+			topLeft := color.NRGBAModel.Convert(face.At(bounds.Min.X, bounds.Min.Y)).(color.NRGBA)
+			bottomRight := color.NRGBAModel.Convert(face.At(bounds.Max.X-1, bounds.Max.Y-1)).(color.NRGBA)
+			if image.Pt(int(topLeft.R), int(topLeft.G)) != first || image.Pt(int(bottomRight.R), int(bottomRight.G)) != last {
+				t.Errorf("%v: face %d goes from %v to %v, expected %v to %v", test.name, i, topLeft, bottomRight, first, last)
+			}
+		}
+	}
+}
+
+func TestCubeFacesErrors(t *testing.T) {
+	square := filledImage(4, 4, color.NRGBA{})
+	tests := []struct {
+		name   string
+		images []image.Image
+	}{
+		{"no images", nil},
+		{"five faces", []image.Image{square, square, square, square, square}},
+		{"not square", []image.Image{square, square, square, square, square, filledImage(4, 2, color.NRGBA{})}},
+		{"different sizes", []image.Image{square, square, square, square, square, filledImage(2, 2, color.NRGBA{})}},
+		{"empty faces", []image.Image{
+			filledImage(0, 0, color.NRGBA{}), filledImage(0, 0, color.NRGBA{}), filledImage(0, 0, color.NRGBA{}),
+			filledImage(0, 0, color.NRGBA{}), filledImage(0, 0, color.NRGBA{}), filledImage(0, 0, color.NRGBA{}),
+		}},
+		{"not a cross", []image.Image{square}},
+		{"uneven cross", []image.Image{filledImage(10, 7, color.NRGBA{})}},
+	}
+
+	for _, test := range tests {
+		if _, err := CubeFaces(test.images...); err == nil {
+			t.Errorf("%v: did not fail", test.name)
+		}
+	}
+
+	faces, err := CubeFaces(square, square, square, square, square, square)
+	if err != nil || len(faces) != 6 {
+		t.Errorf("six faces: got %d faces, %v", len(faces), err)
+	}
+}
@@ -0,0 +1,310 @@
+package textures
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/egonelbre/opengl-tutorial.org/hdr"
+	"github.com/egonelbre/opengl-tutorial.org/internal/texutil"
+)
+
+// MipmapFilter selects how the smaller mipmap levels are computed.
+type MipmapFilter int
+
+const (
+	// DriverFilter uses gl.GenerateMipmap, whose quality depends on the driver
+	DriverFilter MipmapFilter = iota
+	// BoxFilter averages the pixels each texel covers
+	BoxFilter
+	// KaiserFilter is a Kaiser windowed sinc, sharper than the box filter
+	KaiserFilter
+	// LanczosFilter is a three lobed Lanczos filter, the sharpest one
+	LanczosFilter
+)
+
+var mipmapFilterNames = map[MipmapFilter]string{
+	DriverFilter:  "DriverFilter",
+	BoxFilter:     "BoxFilter",
+	KaiserFilter:  "KaiserFilter",
+	LanczosFilter: "LanczosFilter",
+}
+
+func (filter MipmapFilter) String() string {
+	if name, ok := mipmapFilterNames[filter]; ok {
+		return name
+	}
+	return fmt.Sprintf("MipmapFilter(%d)", int(filter))
+}
+
+// GenerateMipmaps returns the full mipmap chain of img, starting with
+// img itself. Each level is half the size of the previous one rounded
+// down, but at least 1, so non-power-of-two sizes are supported.
+//
+// The color channels are weighted by alpha, and when srgb is true
+// they are filtered in linear light. The levels of an *hdr.Image are
+// also *hdr.Image, srgb is ignored for them. The levels can be
+// uploaded with UploadLevels after Prepare or compressed with
+// dds.EncodeLevels.
+func GenerateMipmaps(img image.Image, filter MipmapFilter, srgb bool) ([]image.Image, error) {
+	if filter == DriverFilter {
+		return nil, fmt.Errorf("%v cannot be used without OpenGL", filter)
+	}
+	if _, ok := mipmapFilterNames[filter]; !ok {
+		return nil, fmt.Errorf("Unknown mipmap filter %v", filter)
+	}
+
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return nil, fmt.Errorf("Invalid size %dx%d", bounds.Dx(), bounds.Dy())
+	}
+
+	levels := []image.Image{img}
+	level := newLinearImage(img, srgb)
+	for level.width > 1 || level.height > 1 {
+		width, height, _ := texutil.NextLevel(level.width, level.height, 1)
+		level = level.resize(width, height, filter)
+		if _, ok := img.(*hdr.Image); ok {
+			levels = append(levels, level.toHDR())
+		} else {
+			levels = append(levels, level.toNRGBA64(srgb))
+		}
+	}
+	return levels, nil
+}
+
+// linearImage contains linear premultiplied RGBA values,
+// in [0, 1] unless it was created from an *hdr.Image
+type linearImage struct {
+	width  int
+	height int
+	pix    []float32
+}
+
+func newLinearImage(img image.Image, srgb bool) *linearImage {
+	bounds := img.Bounds()
+	m := &linearImage{
+		width:  bounds.Dx(),
+		height: bounds.Dy(),
+	}
+	m.pix = make([]float32, 4*m.width*m.height)
+
+	if img, ok := img.(*hdr.Image); ok {
+		for i := 0; i < m.width*m.height; i++ {
+			copy(m.pix[4*i:4*i+3], img.Pix[3*i:])
+			m.pix[4*i+3] = 1
+		}
+		return m
+	}
+
+	row := make([]uint16, 4*m.width)
+	for y := 0; y < m.height; y++ {
+		readRow(img, bounds.Min.Y+y, row)
+
+		dst := m.pix[4*m.width*y:]
+		for i := 0; i < len(row); i += 4 {
+			a := float32(row[i+3]) / 0xFFFF
+			for k := 0; k < 3; k++ {
+				v := float32(row[i+k]) / 0xFFFF
+				if srgb {
+					v = srgbToLinear(v)
+				}
+				dst[i+k] = v * a
+			}
+			dst[i+3] = a
+		}
+	}
+	return m
+}
+
+func (m *linearImage) toNRGBA64(srgb bool) *image.NRGBA64 {
+	img := image.NewNRGBA64(image.Rect(0, 0, m.width, m.height))
+	for i := 0; i < len(m.pix); i += 4 {
+		a := clamp01(m.pix[i+3])
+		for k := 0; k < 3; k++ {
+			var v float32
+			if a > 0 {
+				v = clamp01(m.pix[i+k] / a)
+			}
+			if srgb {
+				v = linearToSRGB(v)
+			}
+			putUint16(img.Pix[2*(i+k):], v)
+		}
+		putUint16(img.Pix[2*(i+3):], a)
+	}
+	return img
+}
+
+func (m *linearImage) toHDR() *hdr.Image {
+	img := hdr.NewImage(m.width, m.height)
+	for i := 0; i < m.width*m.height; i++ {
+		copy(img.Pix[3*i:3*i+3], m.pix[4*i:])
+	}
+	return img
+}
+
+// putUint16 stores v in [0, 1] as a big-endian 16-bit value
+func putUint16(dst []byte, v float32) {
+	x := uint16(v*0xFFFF + 0.5)
+	dst[0], dst[1] = byte(x>>8), byte(x)
+}
+
+// resize resamples the image, first horizontally then vertically
+func (m *linearImage) resize(width, height int, filter MipmapFilter) *linearImage {
+	horizontal := &linearImage{width: width, height: m.height}
+	horizontal.pix = make([]float32, 4*width*m.height)
+	for x, c := range contributions(m.width, width, filter) {
+		for y := 0; y < m.height; y++ {
+			dst := horizontal.pix[4*(y*width+x):]
+			for j, w := range c.weights {
+				src := m.pix[4*(y*m.width+c.start+j):]
+				for k := 0; k < 4; k++ {
+					dst[k] += w * src[k]
+				}
+			}
+		}
+	}
+
+	result := &linearImage{width: width, height: height}
+	result.pix = make([]float32, 4*width*height)
+	for y, c := range contributions(m.height, height, filter) {
+		for x := 0; x < width; x++ {
+			dst := result.pix[4*(y*width+x):]
+			for j, w := range c.weights {
+				src := horizontal.pix[4*((c.start+j)*width+x):]
+				for k := 0; k < 4; k++ {
+					dst[k] += w * src[k]
+				}
+			}
+		}
+	}
+	return result
+}
+
+// contribution are the weights of source pixels start, start+1, ...
+type contribution struct {
+	start   int
+	weights []float32
+}
+
+// contributions returns the normalized weights for resampling
+// src pixels to dst pixels, pixels outside are clamped to the edge
+func contributions(src, dst int, filter MipmapFilter) []contribution {
+	scale := float64(src) / float64(dst)
+	result := make([]contribution, dst)
+
+	for i := range result {
+		lo, hi := float64(i)*scale, float64(i+1)*scale
+		center := (lo + hi) / 2
+
+		var kernel func(x float64) float64
+		var support float64
+		switch filter {
+		case KaiserFilter:
+			kernel, support = kaiser, kaiserWidth
+		case LanczosFilter:
+			kernel, support = lanczos, lanczosWidth
+		}
+		if kernel != nil {
+			lo, hi = center-support*scale, center+support*scale
+		}
+
+		first, last := int(math.Floor(lo)), int(math.Ceil(hi))-1
+		start, end := clampInt(first, 0, src-1), clampInt(last, 0, src-1)
+		weights := make([]float32, end-start+1)
+
+		total := 0.0
+		for j := first; j <= last; j++ {
+			var w float64
+			if kernel == nil {
+				// the box filter weights pixels by how much they overlap
+				w = math.Min(float64(j+1), hi) - math.Max(float64(j), lo)
+			} else {
+				w = kernel((float64(j) + 0.5 - center) / scale)
+			}
+			weights[clampInt(j, 0, src-1)-start] += float32(w)
+			total += w
+		}
+		if total != 0 {
+			for j := range weights {
+				weights[j] /= float32(total)
+			}
+		}
+
+		result[i] = contribution{start, weights}
+	}
+	return result
+}
+
+const (
+	kaiserWidth  = 3
+	kaiserAlpha  = 4
+	lanczosWidth = 3
+)
+
+func kaiser(x float64) float64 {
+	if math.Abs(x) >= kaiserWidth {
+		return 0
+	}
+	t := x / kaiserWidth
+	return sinc(x) * besselI0(kaiserAlpha*math.Sqrt(1-t*t)) / besselI0(kaiserAlpha)
+}
+
+func lanczos(x float64) float64 {
+	if math.Abs(x) >= lanczosWidth {
+		return 0
+	}
+	return sinc(x) * sinc(x/lanczosWidth)
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// besselI0 is the modified Bessel function of the first kind
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > 1e-12*sum; k++ {
+		term *= (x / 2 / float64(k)) * (x / 2 / float64(k))
+		sum += term
+	}
+	return sum
+}
+
+func srgbToLinear(v float32) float32 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return float32(math.Pow((float64(v)+0.055)/1.055, 2.4))
+}
+
+func linearToSRGB(v float32) float32 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return float32(1.055*math.Pow(float64(v), 1/2.4) - 0.055)
+}
+
+func clamp01(v float32) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
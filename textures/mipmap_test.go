@@ -0,0 +1,179 @@
+package textures
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/egonelbre/opengl-tutorial.org/dds"
+	"github.com/egonelbre/opengl-tutorial.org/hdr"
+)
+
+func filledImage(width, height int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+func TestGenerateMipmapsSizes(t *testing.T) {
+	tests := []struct {
+		width, height int
+		sizes         []image.Point
+	}{
+		{1, 1, []image.Point{{1, 1}}},
+		{4, 4, []image.Point{{4, 4}, {2, 2}, {1, 1}}},
+		{7, 3, []image.Point{{7, 3}, {3, 1}, {1, 1}}},
+		{5, 8, []image.Point{{5, 8}, {2, 4}, {1, 2}, {1, 1}}},
+	}
+
+	for _, test := range tests {
+		for _, filter := range []MipmapFilter{BoxFilter, KaiserFilter, LanczosFilter} {
+			levels, err := GenerateMipmaps(filledImage(test.width, test.height, color.NRGBA{10, 20, 30, 40}), filter, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var sizes []image.Point
+			for _, level := range levels {
+				sizes = append(sizes, level.Bounds().Size())
+
+				// a solid image stays solid with every filter
+				pixels, err := Prepare(level, Options{Format: RGBA8})
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := 0; i < len(pixels.Data); i += 4 {
+					if got := pixels.Data[i : i+4]; !reflect.DeepEqual(got, []byte{10, 20, 30, 40}) {
+						t.Errorf("%dx%d %v: got %v", test.width, test.height, filter, got)
+						break
+					}
+				}
+			}
+			if !reflect.DeepEqual(sizes, test.sizes) {
+				t.Errorf("%dx%d %v: got sizes %v, expected %v", test.width, test.height, filter, sizes, test.sizes)
+			}
+		}
+	}
+}
+
+func TestGenerateMipmapsBox(t *testing.T) {
+	blackWhite := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	copy(blackWhite.Pix, []byte{0, 0, 0, 255, 255, 255, 255, 255})
+
+	transparent := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	copy(transparent.Pix, []byte{255, 0, 0, 255, 0, 0, 255, 0})
+
+	tests := []struct {
+		name     string
+		img      image.Image
+		srgb     bool
+		expected []byte
+	}{
+		{"linear", blackWhite, false, []byte{128, 128, 128, 255}},
+		{"sRGB", blackWhite, true, []byte{188, 188, 188, 255}},
+		// the transparent pixel does not darken the color
+		{"alpha weighted", transparent, false, []byte{255, 0, 0, 128}},
+	}
+
+	for _, test := range tests {
+		levels, err := GenerateMipmaps(test.img, BoxFilter, test.srgb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pixels, err := Prepare(levels[1], Options{Format: RGBA8})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(pixels.Data, test.expected) {
+			t.Errorf("%v: got %v, expected %v", test.name, pixels.Data, test.expected)
+		}
+	}
+}
+
+func TestGenerateMipmapsEncode(t *testing.T) {
+	// a checker of black and white averages to middle gray in linear light
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 8; x++ {
+			if (x+y)%2 == 0 {
+				img.SetNRGBA(x, y, color.NRGBA{255, 255, 255, 255})
+			} else {
+				img.SetNRGBA(x, y, color.NRGBA{0, 0, 0, 255})
+			}
+		}
+	}
+
+	levels, err := GenerateMipmaps(img, BoxFilter, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := dds.EncodeLevels(&buf, levels, dds.EncodeOptions{Format: dds.BC1_UNORM_SRGB}); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := dds.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Levels) != len(levels) {
+		t.Fatalf("got %d levels, expected %d", len(decoded.Levels), len(levels))
+	}
+	for level := range levels {
+		m, err := decoded.Decompress(level, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Bounds().Size() != levels[level].Bounds().Size() {
+			t.Errorf("level %d is %v, expected %v", level, m.Bounds().Size(), levels[level].Bounds().Size())
+		}
+		if level == 0 {
+			continue
+		}
+		// BC1 stores 5 and 6 bit colors
+		if c := m.NRGBAAt(0, 0); c.R < 185 || c.R > 191 || c.G < 185 || c.G > 191 {
+			t.Errorf("level %d is %v, expected gray around 188", level, c)
+		}
+	}
+}
+
+func TestGenerateMipmapsHDR(t *testing.T) {
+	img := hdr.NewImage(2, 2)
+	for i := range img.Pix {
+		img.Pix[i] = float32(i % 3 * 10)
+	}
+
+	levels, err := GenerateMipmaps(img, BoxFilter, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(levels) != 2 {
+		t.Fatalf("got %d levels", len(levels))
+	}
+	last, ok := levels[1].(*hdr.Image)
+	if !ok {
+		t.Fatalf("got %T, expected *hdr.Image", levels[1])
+	}
+	// values above one are kept and srgb is ignored
+	for k, expected := range []float32{0, 10, 20} {
+		if got := last.Pix[k]; got < expected-1e-4 || got > expected+1e-4 {
+			t.Errorf("channel %d is %v, expected %v", k, got, expected)
+		}
+	}
+}
+
+func TestGenerateMipmapsErrors(t *testing.T) {
+	if _, err := GenerateMipmaps(filledImage(2, 2, color.NRGBA{}), DriverFilter, false); err == nil {
+		t.Errorf("DriverFilter did not fail")
+	}
+	if _, err := GenerateMipmaps(filledImage(2, 2, color.NRGBA{}), MipmapFilter(100), false); err == nil {
+		t.Errorf("unknown filter did not fail")
+	}
+	if _, err := GenerateMipmaps(filledImage(0, 2, color.NRGBA{}), BoxFilter, false); err == nil {
+		t.Errorf("empty image did not fail")
+	}
+}
@@ -0,0 +1,232 @@
+package textures
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/egonelbre/opengl-tutorial.org/hdr"
+)
+
+// PixelFormat is the layout of the pixels in a Pixels buffer.
+type PixelFormat int
+
+const (
+	RGBA8 PixelFormat = iota
+	RGB8
+	R8
+	RG8
+	RGBA16
+	RGBA32F
+	RGB16F
+	RGB32F
+)
+
+var pixelFormatNames = map[PixelFormat]string{
+	RGBA8:   "RGBA8",
+	RGB8:    "RGB8",
+	R8:      "R8",
+	RG8:     "RG8",
+	RGBA16:  "RGBA16",
+	RGBA32F: "RGBA32F",
+	RGB16F:  "RGB16F",
+	RGB32F:  "RGB32F",
+}
+
+func (format PixelFormat) String() string {
+	if name, ok := pixelFormatNames[format]; ok {
+		return name
+	}
+	return fmt.Sprintf("PixelFormat(%d)", int(format))
+}
+
+// Channels returns the number of channels in a pixel.
+func (format PixelFormat) Channels() int {
+	switch format {
+	case R8:
+		return 1
+	case RG8:
+		return 2
+	case RGB8, RGB16F, RGB32F:
+		return 3
+	}
+	return 4
+}
+
+// ChannelSize returns the size of a channel in bytes.
+func (format PixelFormat) ChannelSize() int {
+	switch format {
+	case RGBA16, RGB16F:
+		return 2
+	case RGBA32F, RGB32F:
+		return 4
+	}
+	return 1
+}
+
+// PixelSize returns the size of a pixel in bytes.
+func (format PixelFormat) PixelSize() int {
+	return format.Channels() * format.ChannelSize()
+}
+
+// Pixels is a tightly packed image, ready to be uploaded.
+//
+// Rows follow each other without padding, starting with the top
+// row unless the image was flipped. 16-bit and float channels
+// are stored in little-endian byte order, RGB16F as half floats.
+type Pixels struct {
+	Format PixelFormat
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// Stride returns the size of a row in bytes.
+func (pixels *Pixels) Stride() int {
+	return pixels.Width * pixels.Format.PixelSize()
+}
+
+// Prepare converts img into a Pixels buffer using the Format, FlipY
+// and Premultiply fields of opts. R8 and RG8 keep the red and green
+// channels, RGB8 drops alpha. It does not call OpenGL.
+//
+// Values of an *hdr.Image are kept as is in the float formats
+// and clamped to [0, 1] in the others.
+func Prepare(img image.Image, opts Options) (*Pixels, error) {
+	if _, ok := pixelFormatNames[opts.Format]; !ok {
+		return nil, fmt.Errorf("Unknown pixel format %v", opts.Format)
+	}
+
+	bounds := img.Bounds()
+	pixels := &Pixels{
+		Format: opts.Format,
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+	}
+	pixels.Data = make([]byte, pixels.Stride()*pixels.Height)
+
+	row := make([]float32, 4*pixels.Width)
+	ldr := make([]uint16, 4*pixels.Width)
+	for y := 0; y < pixels.Height; y++ {
+		if floats, ok := img.(*hdr.Image); ok {
+			for x := 0; x < floats.Width; x++ {
+				copy(row[4*x:4*x+3], floats.Pix[3*(y*floats.Width+x):])
+				row[4*x+3] = 1
+			}
+		} else {
+			readRow(img, bounds.Min.Y+y, ldr)
+			for i, v := range ldr {
+				row[i] = float32(v) / 0xFFFF
+			}
+		}
+
+		if opts.Premultiply {
+			premultiply(row)
+		}
+
+		dst := y
+		if opts.FlipY {
+			dst = pixels.Height - 1 - y
+		}
+		pixels.pack(pixels.Data[dst*pixels.Stride():(dst+1)*pixels.Stride()], row)
+	}
+
+	return pixels, nil
+}
+
+// readRow reads row y of img as non-premultiplied 16-bit RGBA
+func readRow(img image.Image, y int, row []uint16) {
+	bounds := img.Bounds()
+
+	switch img := img.(type) {
+	case *image.NRGBA:
+		src := img.Pix[img.PixOffset(bounds.Min.X, y):]
+		for i := range row {
+			row[i] = uint16(src[i]) * 0x101
+		}
+	case *image.NRGBA64:
+		src := img.Pix[img.PixOffset(bounds.Min.X, y):]
+		for i := range row {
+			row[i] = uint16(src[2*i])<<8 | uint16(src[2*i+1])
+		}
+	case *image.Gray:
+		src := img.Pix[img.PixOffset(bounds.Min.X, y):]
+		for x := 0; x < len(row)/4; x++ {
+			v := uint16(src[x]) * 0x101
+			row[4*x], row[4*x+1], row[4*x+2], row[4*x+3] = v, v, v, 0xFFFF
+		}
+	default:
+		for x := 0; x < len(row)/4; x++ {
+			c := color.NRGBA64Model.Convert(img.At(bounds.Min.X+x, y)).(color.NRGBA64)
+			row[4*x], row[4*x+1], row[4*x+2], row[4*x+3] = c.R, c.G, c.B, c.A
+		}
+	}
+}
+
+// premultiply multiplies the color channels of a row with alpha
+func premultiply(row []float32) {
+	for i := 0; i+3 < len(row); i += 4 {
+		for k := 0; k < 3; k++ {
+			row[i+k] *= row[i+3]
+		}
+	}
+}
+
+// pack converts a row of float RGBA into the pixel format
+func (pixels *Pixels) pack(dst []byte, row []float32) {
+	channels := pixels.Format.Channels()
+	for x := 0; x < pixels.Width; x++ {
+		src := row[4*x : 4*x+4]
+		for k, v := range src[:channels] {
+			switch pixels.Format {
+			case RGBA16:
+				binary.LittleEndian.PutUint16(dst[2*k:], uint16(clamp01(v)*0xFFFF+0.5))
+			case RGB16F:
+				binary.LittleEndian.PutUint16(dst[2*k:], floatToHalf(v))
+			case RGBA32F, RGB32F:
+				binary.LittleEndian.PutUint32(dst[4*k:], math.Float32bits(v))
+			default:
+				dst[k] = byte(clamp01(v)*0xFF + 0.5)
+			}
+		}
+		dst = dst[pixels.Format.PixelSize():]
+	}
+}
+
+// floatToHalf converts v to IEEE 754 half precision, rounding
+// to nearest; values that are too large become infinity
+func floatToHalf(v float32) uint16 {
+	bits := math.Float32bits(v)
+	sign := uint16(bits>>16) & 0x8000
+	exponent := int(bits>>23&0xFF) - 127 + 15
+	mantissa := bits & 0x7FFFFF
+
+	switch {
+	case bits&0x7FFFFFFF > 0x7F800000:
+		// NaN
+		return sign | 0x7E00
+	case exponent >= 0x1F:
+		return sign | 0x7C00
+	case exponent <= 0:
+		// subnormal or zero
+		if exponent < -10 {
+			return sign
+		}
+		mantissa |= 0x800000
+		shift := uint(14 - exponent)
+		half := uint16(mantissa >> shift)
+		if mantissa>>(shift-1)&1 != 0 {
+			half++
+		}
+		return sign | half
+	}
+
+	half := sign | uint16(exponent)<<10 | uint16(mantissa>>13)
+	if mantissa&0x1000 != 0 {
+		// rounding can carry into the exponent, which is correct
+		half++
+	}
+	return half
+}
@@ -0,0 +1,142 @@
+package textures
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/egonelbre/opengl-tutorial.org/hdr"
+)
+
+// testImage returns a 3x2 image with varying alpha
+func testImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	copy(img.Pix, []byte{
+		255, 0, 0, 255, 0, 255, 0, 128, 0, 0, 255, 0,
+		10, 20, 30, 40, 50, 60, 70, 80, 255, 255, 255, 255,
+	})
+	return img
+}
+
+func uint16s(values ...uint16) []byte {
+	data := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint16(data[2*i:], v)
+	}
+	return data
+}
+
+func float32s(values ...float32) []byte {
+	data := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+	return data
+}
+
+func TestPrepare(t *testing.T) {
+	floats := hdr.NewImage(2, 1)
+	copy(floats.Pix, []float32{0, 1, -2, 0.5, 65504, 1e6})
+
+	// the sub-image starts at 1,1 of the backing image
+	sub := testImage().SubImage(image.Rect(1, 1, 3, 2))
+
+	gray := image.NewGray(image.Rect(0, 0, 2, 1))
+	copy(gray.Pix, []byte{0, 77})
+
+	tests := []struct {
+		name     string
+		img      image.Image
+		opts     Options
+		width    int
+		height   int
+		expected []byte
+	}{
+		{"RGBA8", testImage(), Options{Format: RGBA8}, 3, 2, testImage().Pix},
+		{"RGB8", testImage(), Options{Format: RGB8}, 3, 2, []byte{
+			255, 0, 0, 0, 255, 0, 0, 0, 255,
+			10, 20, 30, 50, 60, 70, 255, 255, 255,
+		}},
+		{"R8", testImage(), Options{Format: R8}, 3, 2, []byte{255, 0, 0, 10, 50, 255}},
+		{"RG8", gray, Options{Format: RG8}, 2, 1, []byte{0, 0, 77, 77}},
+		{"RGBA16", sub, Options{Format: RGBA16}, 2, 1, uint16s(
+			50*0x101, 60*0x101, 70*0x101, 80*0x101, 0xFFFF, 0xFFFF, 0xFFFF, 0xFFFF,
+		)},
+		{"FlipY", testImage(), Options{Format: R8, FlipY: true}, 3, 2, []byte{10, 50, 255, 255, 0, 0}},
+		{"Premultiply", testImage(), Options{Format: RGBA8, Premultiply: true}, 3, 2, []byte{
+			255, 0, 0, 255, 0, 128, 0, 128, 0, 0, 0, 0,
+			2, 3, 5, 40, 16, 19, 22, 80, 255, 255, 255, 255,
+		}},
+		{"sub-image", sub, Options{Format: RGBA8}, 2, 1, []byte{50, 60, 70, 80, 255, 255, 255, 255}},
+		{"RGB16F", floats, Options{Format: RGB16F}, 2, 1, uint16s(0x0000, 0x3C00, 0xC000, 0x3800, 0x7BFF, 0x7C00)},
+		{"RGBA32F", floats, Options{Format: RGBA32F}, 2, 1, float32s(0, 1, -2, 1, 0.5, 65504, 1e6, 1)},
+		{"HDR clamped", floats, Options{Format: RGB8}, 2, 1, []byte{0, 255, 0, 128, 255, 255}},
+	}
+
+	for _, test := range tests {
+		pixels, err := Prepare(test.img, test.opts)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if pixels.Format != test.opts.Format || pixels.Width != test.width || pixels.Height != test.height {
+			t.Errorf("%v: got %v %dx%d, expected %v %dx%d", test.name,
+				pixels.Format, pixels.Width, pixels.Height, test.opts.Format, test.width, test.height)
+		}
+		if len(pixels.Data) != pixels.Stride()*pixels.Height {
+			t.Errorf("%v: got %d bytes, expected %d", test.name, len(pixels.Data), pixels.Stride()*pixels.Height)
+		}
+		if !bytes.Equal(pixels.Data, test.expected) {
+			t.Errorf("%v: got %v, expected %v", test.name, pixels.Data, test.expected)
+		}
+	}
+
+	if _, err := Prepare(testImage(), Options{Format: PixelFormat(100)}); err == nil {
+		t.Errorf("unknown format did not fail")
+	}
+}
+
+func TestPrepareConverts(t *testing.T) {
+	// premultiplied images are converted to non-premultiplied
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.SetRGBA(0, 0, color.RGBA{100, 50, 0, 200})
+
+	pixels, err := Prepare(img, Options{Format: RGBA8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []byte{127, 64, 0, 200}; !bytes.Equal(pixels.Data, expected) {
+		t.Errorf("got %v, expected %v", pixels.Data, expected)
+	}
+}
+
+func TestFloatToHalf(t *testing.T) {
+	tests := []struct {
+		value float32
+		half  uint16
+	}{
+		{0, 0x0000},
+		{float32(math.Copysign(0, -1)), 0x8000},
+		{1, 0x3C00},
+		{-2, 0xC000},
+		{0.333, 0x3554},
+		{65504, 0x7BFF},
+		{65520, 0x7C00},
+		{1e6, 0x7C00},
+		{float32(math.Inf(-1)), 0xFC00},
+		{1e-6, 0x0011},
+		{6e-8, 0x0001},
+		{1e-9, 0x0000},
+	}
+	for _, test := range tests {
+		if got := floatToHalf(test.value); got != test.half {
+			t.Errorf("%v: got %#04x, expected %#04x", test.value, got, test.half)
+		}
+	}
+	if got := floatToHalf(float32(math.NaN())); got&0x7C00 != 0x7C00 || got&0x3FF == 0 {
+		t.Errorf("NaN: got %#04x", got)
+	}
+}
@@ -1,9 +1,10 @@
 package textures
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"image"
-	"image/draw"
 	"os"
 
 	_ "image/jpeg"
@@ -12,46 +13,332 @@ import (
 	"github.com/go-gl/gl/v4.1-core/gl"
 )
 
+// Options control how a texture is stored and sampled.
+//
+// Zero wrap modes and filters are replaced with the
+// values from DefaultOptions, a zero MinFilter with
+// gl.LINEAR when Mipmaps is false.
+type Options struct {
+	// WrapS, WrapT and WrapR are gl.REPEAT, gl.MIRRORED_REPEAT,
+	// gl.CLAMP_TO_EDGE or gl.CLAMP_TO_BORDER; WrapR is only
+	// used for cube maps
+	WrapS int32
+	WrapT int32
+	WrapR int32
+
+	// MinFilter is any of the minifying filters, the mipmap
+	// filters require Mipmaps; MagFilter is gl.NEAREST or gl.LINEAR
+	MinFilter int32
+	MagFilter int32
+
+	// Anisotropy is the maximum degree of anisotropic filtering,
+	// values up to 1 disable it; it is clamped to what the
+	// implementation supports
+	Anisotropy float32
+
+	// Mipmaps generates the mipmap chain with MipmapFilter
+	Mipmaps      bool
+	MipmapFilter MipmapFilter
+
+	// SRGB uses an sRGB internal format, for color textures
+	// whose values are not linear; only RGBA8 and RGB8 support it
+	SRGB bool
+
+	// Format is the pixel format the image is converted to
+	Format PixelFormat
+
+	// FlipY stores the bottom row first, as OpenGL expects
+	FlipY bool
+
+	// Premultiply multiplies the color channels with alpha
+	Premultiply bool
+
+	// Unit is the texture unit the texture is bound to,
+	// 0 corresponds to gl.TEXTURE0
+	Unit uint32
+}
+
+// DefaultOptions repeat the texture and use trilinear filtering.
+var DefaultOptions = Options{
+	WrapS:     gl.REPEAT,
+	WrapT:     gl.REPEAT,
+	WrapR:     gl.REPEAT,
+	MinFilter: gl.LINEAR_MIPMAP_LINEAR,
+	MagFilter: gl.LINEAR,
+	Mipmaps:   true,
+}
+
+// Load loads a PNG or JPEG file as a texture using DefaultOptions.
 func Load(filename string) (uint32, error) {
-	file, err := os.Open(filename)
+	return LoadWithOptions(filename, DefaultOptions)
+}
+
+// LoadWithOptions loads a PNG or JPEG file as a texture.
+func LoadWithOptions(filename string, opts Options) (uint32, error) {
+	img, err := decodeFile(filename)
 	if err != nil {
 		return 0, err
 	}
-	img, _, err := image.Decode(file)
+	return Upload(img, opts)
+}
+
+func decodeFile(filename string) (image.Image, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("Decoding %v failed: %v", filename, err)
+	}
+	return img, nil
+}
+
+// Upload converts img with Prepare and uploads it as a new
+// TEXTURE_2D texture, which is left bound to opts.Unit.
+func Upload(img image.Image, opts Options) (uint32, error) {
+	levels, err := prepareLevels(img, opts)
 	if err != nil {
 		return 0, err
 	}
+	return UploadLevels(levels, opts)
+}
+
+// prepareLevels generates the mipmaps when they are not generated
+// by the driver and prepares each level
+func prepareLevels(img image.Image, opts Options) ([]*Pixels, error) {
+	levels := []image.Image{img}
+	if opts.Mipmaps && opts.MipmapFilter != DriverFilter {
+		var err error
+		levels, err = GenerateMipmaps(img, opts.MipmapFilter, opts.SRGB)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var pixels []*Pixels
+	for _, level := range levels {
+		prepared, err := Prepare(level, opts)
+		if err != nil {
+			return nil, err
+		}
+		pixels = append(pixels, prepared)
+	}
+	return pixels, nil
+}
+
+// UploadPixels uploads pixels as a new TEXTURE_2D texture, see UploadLevels.
+func UploadPixels(pixels *Pixels, opts Options) (uint32, error) {
+	return UploadLevels([]*Pixels{pixels}, opts)
+}
+
+// UploadLevels uploads a mipmap chain as a new TEXTURE_2D texture,
+// which is left bound to opts.Unit. With a single level and
+// opts.Mipmaps the chain is generated with gl.GenerateMipmap.
+//
+// The Format, FlipY, Premultiply and MipmapFilter fields of
+// opts are ignored, since the levels have already been prepared.
+func UploadLevels(levels []*Pixels, opts Options) (uint32, error) {
+	return upload(gl.TEXTURE_2D, [][]*Pixels{levels}, opts)
+}
+
+// upload uploads the mipmap chains of the faces of a TEXTURE_2D
+// or TEXTURE_CUBE_MAP texture
+func upload(target uint32, faces [][]*Pixels, opts Options) (uint32, error) {
+	opts = opts.withDefaults()
+	if err := opts.validate(); err != nil {
+		return 0, err
+	}
+	if len(faces) == 0 || len(faces[0]) == 0 {
+		return 0, errors.New("No image data")
+	}
 
-	rgba := image.NewRGBA(img.Bounds())
-	if rgba.Stride != rgba.Rect.Size().X*4 {
-		return 0, fmt.Errorf("unsupported stride")
+	levels := faces[0]
+	format, err := glFormat(levels[0].Format, opts.SRGB)
+	if err != nil {
+		return 0, err
+	}
+	for _, face := range faces {
+		if len(face) != len(levels) {
+			return 0, fmt.Errorf("Faces have %d and %d mipmap levels", len(levels), len(face))
+		}
+		for level, pixels := range face {
+			if pixels.Format != levels[0].Format {
+				return 0, fmt.Errorf("Mipmap level %d is %v, expected %v", level, pixels.Format, levels[0].Format)
+			}
+		}
 	}
-	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
 
 	var texture uint32
 	gl.GenTextures(1, &texture)
-	gl.ActiveTexture(gl.TEXTURE0)
-	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.ActiveTexture(gl.TEXTURE0 + opts.Unit)
+	gl.BindTexture(target, texture)
 
-	gl.TexImage2D(
-		gl.TEXTURE_2D,
-		0,
-		gl.RGBA,
-		int32(rgba.Rect.Size().X),
-		int32(rgba.Rect.Size().Y),
-		0,
-		gl.RGBA,
-		gl.UNSIGNED_BYTE,
-		gl.Ptr(rgba.Pix))
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	for i, face := range faces {
+		faceTarget := target
+		if target == gl.TEXTURE_CUBE_MAP {
+			faceTarget = gl.TEXTURE_CUBE_MAP_POSITIVE_X + uint32(i)
+		}
 
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR_MIPMAP_LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+		for level, pixels := range face {
+			gl.TexImage2D(
+				faceTarget,
+				int32(level),
+				format.internal,
+				int32(pixels.Width),
+				int32(pixels.Height),
+				0,
+				format.format,
+				format.dataType,
+				gl.Ptr(pixels.Data))
+		}
+	}
+	if err := glError("Uploading texture"); err != nil {
+		gl.DeleteTextures(1, &texture)
+		return 0, err
+	}
 
-	gl.GenerateMipmap(gl.TEXTURE_2D)
+	opts.setParameters(target)
+	if len(levels) > 1 {
+		gl.TexParameteri(target, gl.TEXTURE_MAX_LEVEL, int32(len(levels)-1))
+	} else if opts.Mipmaps {
+		gl.GenerateMipmap(target)
+	}
 
-	checkerror()
+	if err := glError("Setting texture parameters"); err != nil {
+		gl.DeleteTextures(1, &texture)
+		return 0, err
+	}
 
 	return texture, nil
 }
+
+// pixelTransfer is the OpenGL description of a pixel format
+type pixelTransfer struct {
+	internal int32
+	format   uint32
+	dataType uint32
+}
+
+// glFormat returns the internal format, format and type for uploading
+func glFormat(format PixelFormat, srgb bool) (pixelTransfer, error) {
+	if srgb && format != RGBA8 && format != RGB8 {
+		return pixelTransfer{}, fmt.Errorf("Pixel format %v does not have an sRGB variant", format)
+	}
+
+	switch format {
+	case RGBA8:
+		if srgb {
+			return pixelTransfer{gl.SRGB8_ALPHA8, gl.RGBA, gl.UNSIGNED_BYTE}, nil
+		}
+		return pixelTransfer{gl.RGBA8, gl.RGBA, gl.UNSIGNED_BYTE}, nil
+	case RGB8:
+		if srgb {
+			return pixelTransfer{gl.SRGB8, gl.RGB, gl.UNSIGNED_BYTE}, nil
+		}
+		return pixelTransfer{gl.RGB8, gl.RGB, gl.UNSIGNED_BYTE}, nil
+	case R8:
+		return pixelTransfer{gl.R8, gl.RED, gl.UNSIGNED_BYTE}, nil
+	case RG8:
+		return pixelTransfer{gl.RG8, gl.RG, gl.UNSIGNED_BYTE}, nil
+	case RGBA16:
+		return pixelTransfer{gl.RGBA16, gl.RGBA, gl.UNSIGNED_SHORT}, nil
+	case RGBA32F:
+		return pixelTransfer{gl.RGBA32F, gl.RGBA, gl.FLOAT}, nil
+	case RGB16F:
+		return pixelTransfer{gl.RGB16F, gl.RGB, gl.HALF_FLOAT}, nil
+	case RGB32F:
+		return pixelTransfer{gl.RGB32F, gl.RGB, gl.FLOAT}, nil
+	}
+	return pixelTransfer{}, fmt.Errorf("Unknown pixel format %v", format)
+}
+
+// withDefaults replaces the zero wrap modes and filters
+func (opts Options) withDefaults() Options {
+	if opts.WrapS == 0 {
+		opts.WrapS = DefaultOptions.WrapS
+	}
+	if opts.WrapT == 0 {
+		opts.WrapT = DefaultOptions.WrapT
+	}
+	if opts.WrapR == 0 {
+		opts.WrapR = DefaultOptions.WrapR
+	}
+	if opts.MinFilter == 0 {
+		opts.MinFilter = DefaultOptions.MinFilter
+		if !opts.Mipmaps {
+			// the default filter requires mipmaps
+			opts.MinFilter = gl.LINEAR
+		}
+	}
+	if opts.MagFilter == 0 {
+		opts.MagFilter = DefaultOptions.MagFilter
+	}
+	return opts
+}
+
+func (opts Options) validate() error {
+	for _, wrap := range []int32{opts.WrapS, opts.WrapT, opts.WrapR} {
+		switch wrap {
+		case gl.REPEAT, gl.MIRRORED_REPEAT, gl.CLAMP_TO_EDGE, gl.CLAMP_TO_BORDER:
+		default:
+			return fmt.Errorf("Invalid wrap mode 0x%x", wrap)
+		}
+	}
+
+	switch opts.MinFilter {
+	case gl.NEAREST, gl.LINEAR:
+	case gl.NEAREST_MIPMAP_NEAREST, gl.LINEAR_MIPMAP_NEAREST,
+		gl.NEAREST_MIPMAP_LINEAR, gl.LINEAR_MIPMAP_LINEAR:
+		if !opts.Mipmaps {
+			return fmt.Errorf("Min filter 0x%x requires mipmaps", opts.MinFilter)
+		}
+	default:
+		return fmt.Errorf("Invalid min filter 0x%x", opts.MinFilter)
+	}
+
+	switch opts.MagFilter {
+	case gl.NEAREST, gl.LINEAR:
+	default:
+		return fmt.Errorf("Invalid mag filter 0x%x, expected NEAREST or LINEAR", opts.MagFilter)
+	}
+
+	return nil
+}
+
+// setParameters sets the wrap modes, filters and
+// anisotropy of the texture bound to target
+func (opts Options) setParameters(target uint32) {
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_S, opts.WrapS)
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_T, opts.WrapT)
+	if target == gl.TEXTURE_CUBE_MAP {
+		gl.TexParameteri(target, gl.TEXTURE_WRAP_R, opts.WrapR)
+	}
+	gl.TexParameteri(target, gl.TEXTURE_MIN_FILTER, opts.MinFilter)
+	gl.TexParameteri(target, gl.TEXTURE_MAG_FILTER, opts.MagFilter)
+
+	if opts.Anisotropy > 1 {
+		// anisotropic filtering is an extension in OpenGL 4.1,
+		// the query fails when it is not available
+		var max float32
+		gl.GetFloatv(gl.MAX_TEXTURE_MAX_ANISOTROPY, &max)
+		if gl.GetError() == gl.NO_ERROR && max > 1 {
+			anisotropy := opts.Anisotropy
+			if anisotropy > max {
+				anisotropy = max
+			}
+			gl.TexParameterf(target, gl.TEXTURE_MAX_ANISOTROPY, anisotropy)
+		}
+	}
+}
+
+// glError returns the pending OpenGL error, if any
+func glError(operation string) error {
+	if code := gl.GetError(); code != gl.NO_ERROR {
+		return fmt.Errorf("%v failed: OpenGL error 0x%x", operation, code)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package textures
+
+import (
+	"testing"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+)
+
+func TestOptionsDefaults(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      Options
+		minFilter int32
+		valid     bool
+	}{
+		{"zero", Options{}, gl.LINEAR, true},
+		{"wrap only", Options{WrapS: gl.CLAMP_TO_EDGE, WrapT: gl.CLAMP_TO_EDGE}, gl.LINEAR, true},
+		{"mipmaps", Options{Mipmaps: true}, gl.LINEAR_MIPMAP_LINEAR, true},
+		{"defaults", DefaultOptions, gl.LINEAR_MIPMAP_LINEAR, true},
+		{"nearest", Options{MinFilter: gl.NEAREST}, gl.NEAREST, true},
+		{"mipmap filter without mipmaps", Options{MinFilter: gl.LINEAR_MIPMAP_NEAREST}, gl.LINEAR_MIPMAP_NEAREST, false},
+		{"invalid wrap", Options{WrapT: gl.LINEAR}, gl.LINEAR, false},
+		{"invalid mag filter", Options{MagFilter: gl.LINEAR_MIPMAP_LINEAR}, gl.LINEAR, false},
+	}
+
+	for _, test := range tests {
+		opts := test.opts.withDefaults()
+		if opts.MinFilter != test.minFilter {
+			t.Errorf("%v: got min filter 0x%x, expected 0x%x", test.name, opts.MinFilter, test.minFilter)
+		}
+		if opts.WrapS == 0 || opts.WrapT == 0 || opts.WrapR == 0 || opts.MagFilter == 0 {
+			t.Errorf("%v: zero values were not replaced %+v", test.name, opts)
+		}
+		if err := opts.validate(); (err == nil) != test.valid {
+			t.Errorf("%v: got error %v, expected valid %v", test.name, err, test.valid)
+		}
+	}
+}